@@ -4,68 +4,250 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/output"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/policy"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/pricing"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/promql"
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/service"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
 	"github.com/spf13/cobra"
 )
 
 var (
-	rsNamespace string
-	rsCluster   string
-	rsWindow    string
-	rsFormat    string
-	rsCSVOut    string
-	rsHelmPatch string
+	rsNamespaces     []string
+	rsNamespaceRegex string
+	rsAllNamespaces  bool
+	rsExcludeNs      string
+	rsClusters       []string
+	rsClusterRegex   string
+	rsDivergence     float64
+	rsWindow         string
+	rsFormat         string
+	rsCSVOut         string
+	rsHelmPatch      string
 
 	rsTargetUtil   float64
 	rsSafetyFactor float64
 	rsMemRoundMiB  int64
 	rsCPURoundm    int64
 
+	rsMaxMemReducePct   float64
+	rsMaxMemIncreasePct float64
+	rsMaxCPUReducePct   float64
+	rsMaxCPUIncreasePct float64
+	rsMemFloorMiB       int64
+	rsMemCeilingMiB     int64
+	rsCPUFloorm         int64
+	rsCPUCeilingm       int64
+
 	rsOOMWindow string
 	rsSubStep   string
 	rsTopK      int
 	rsBottom    bool
+	rsSortBy    []string
+
+	rsOnly             []string
+	rsContainerInclude string
+	rsContainerExclude string
+	rsMinMemDeltaMiB   int64
+	rsMinCPUDeltam     int64
+	rsMissing          string
+
+	rsClusterLabel   string
+	rsIdentityLabels []string
+	rsRequireLabels  map[string]string
+	rsGroupBy        string
+	rsSidecars       []string
+	rsSidecarSafety  float64
+	rsPolicy         string
+	rsLowConfidence  string
+
+	rsMemStat string
+	rsCPUStat string
+	rsStats   []string
+	rsMode    string
+	rsPricing string
+
+	rsMemLimitRatio float64
+	rsCPULimitRatio float64
+	rsCPULimit      string
+
+	rsRecordDir string
+	rsReplayDir string
+
+	rsConcurrency  int
+	rsQueryTimeout time.Duration
+	rsTimeout      time.Duration
 )
 
 var benchRightsizeCmd = &cobra.Command{
 	Use:   "rightsize",
 	Short: "Compute memory+CPU over/under-provisioning and recommend new requests",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), rsTimeout)
 		defer cancel()
 
-		svc := service.NewRightsizeService()
+		var byWorkload bool
+		switch rsGroupBy {
+		case service.GroupByWorkload:
+			byWorkload = true
+		case service.GroupByContainer:
+		default:
+			return fmt.Errorf("unknown --group-by %q (want workload|container)", rsGroupBy)
+		}
+
+		memStat, err := promql.ParseStat(rsMemStat)
+		if err != nil {
+			return fmt.Errorf("--mem-stat: %w", err)
+		}
+		cpuStat, err := promql.ParseStat(rsCPUStat)
+		if err != nil {
+			return fmt.Errorf("--cpu-stat: %w", err)
+		}
+		var extraStats []promql.Stat
+		for _, s := range rsStats {
+			st, err := promql.ParseStat(s)
+			if err != nil {
+				return fmt.Errorf("--stats: %w", err)
+			}
+			if !slices.Contains(extraStats, st) {
+				extraStats = append(extraStats, st)
+			}
+		}
+
+		// --bottom=false is the old spelling of a descending mem-ratio sort
+		specs := rsSortBy
+		if !cmd.Flags().Changed("sort-by") && !rsBottom {
+			specs = []string{"mem-ratio:desc"}
+		}
+		sortBy, err := service.ParseSortKeys(specs)
+		if err != nil {
+			return fmt.Errorf("--sort-by: %w", err)
+		}
+
+		pol := policy.Default()
+		if rsPolicy != "" {
+			if pol, err = policy.Load(rsPolicy); err != nil {
+				return err
+			}
+		}
+
+		var prices *pricing.Pricing
+		if rsPricing != "" {
+			if prices, err = pricing.Load(rsPricing); err != nil {
+				return err
+			}
+		}
+
+		ds, err := rightsizeDatasource()
+		if err != nil {
+			return err
+		}
+
+		svc := service.NewRightsizeService(ds)
 
 		results, meta, err := svc.Run(ctx, service.RightsizeParams{
-			Namespace:    rsNamespace,
-			Cluster:      rsCluster,
+			Namespaces: promql.NamespaceSelector{
+				Names:   rsNamespaces,
+				Include: rsNamespaceRegex,
+				Exclude: rsExcludeNs,
+				All:     rsAllNamespaces,
+			},
+			Clusters:     rsClusters,
+			ClusterRegex: rsClusterRegex,
 			Window:       rsWindow,
 			SubqueryStep: rsSubStep,
 
 			OOMWindow: rsOOMWindow,
 
+			MemStat:    memStat,
+			CPUStat:    cpuStat,
+			ExtraStats: extraStats,
+			Mode:       rsMode,
+
 			TargetUtil:   rsTargetUtil,
 			SafetyFactor: rsSafetyFactor,
 			MemRoundMiB:  rsMemRoundMiB,
 			CPURoundm:    rsCPURoundm,
 
+			Guardrails: service.Guardrails{
+				MaxMemReducePct:   rsMaxMemReducePct,
+				MaxMemIncreasePct: rsMaxMemIncreasePct,
+				MaxCPUReducePct:   rsMaxCPUReducePct,
+				MaxCPUIncreasePct: rsMaxCPUIncreasePct,
+				MemFloorMiB:       rsMemFloorMiB,
+				MemCeilingMiB:     rsMemCeilingMiB,
+				CPUFloorm:         rsCPUFloorm,
+				CPUCeilingm:       rsCPUCeilingm,
+			},
+
+			Filter: service.Filter{
+				Only:             rsOnly,
+				ContainerInclude: rsContainerInclude,
+				ContainerExclude: rsContainerExclude,
+				MinMemDeltaMiB:   rsMinMemDeltaMiB,
+				MinCPUDeltam:     rsMinCPUDeltam,
+				Missing:          rsMissing,
+			},
+
+			SortBy: sortBy,
 			TopK:   rsTopK,
-			Bottom: rsBottom,
+
+			Labels: promql.LabelSchema{
+				Cluster:   rsClusterLabel,
+				Extra:     rsIdentityLabels,
+				Required:  rsRequireLabels,
+				Workloads: byWorkload,
+			},
+
+			SidecarPatterns: rsSidecars,
+			SidecarSafety:   rsSidecarSafety,
+
+			Policy:        pol,
+			LowConfidence: rsLowConfidence,
+			Pricing:       prices,
+
+			MemLimitRatio:    rsMemLimitRatio,
+			CPULimitRatio:    rsCPULimitRatio,
+			CPULimitStrategy: rsCPULimit,
+
+			Concurrency:  rsConcurrency,
+			QueryTimeout: rsQueryTimeout,
 		})
 		if err != nil {
 			return err
 		}
 
+		for _, f := range meta.FailedSignals {
+			fmt.Fprintf(os.Stderr, "⚠ optional signal %q unavailable, its checks were skipped: %s\n", f.Signal, f.Error)
+		}
+		for _, w := range meta.Warnings {
+			fmt.Fprintf(os.Stderr, "⚠ %s\n", w)
+		}
+
+		// Multi-cluster runs also get one merged row per service
+		var merged []model.MergedResult
+		if len(meta.Clusters) > 1 {
+			merged = service.MergeClusters(results, rsDivergence)
+		}
+
 		// ---------- STDOUT ----------
 		switch rsFormat {
 		case "table":
-			output.RenderTable(results)
+			output.RenderTable(results, meta)
+			if merged != nil {
+				output.RenderMergedTable(merged)
+			}
 
 		case "json":
-			return fmt.Errorf("json format not implemented yet")
+			if err := output.WriteJSON(os.Stdout, results, merged, meta); err != nil {
+				return fmt.Errorf("write json: %w", err)
+			}
 
 		default:
 			return fmt.Errorf("unknown format: %s", rsFormat)
@@ -81,7 +263,12 @@ var benchRightsizeCmd = &cobra.Command{
 
 		// ---------- HELM PATCH ----------
 		if rsHelmPatch != "" {
-			if err := output.WriteHelmValuesPatch(rsHelmPatch, results); err != nil {
+			// One value per service: across clusters, the merged (worst-case) sizing
+			helm := results
+			if merged != nil {
+				helm = output.MergedResults(merged)
+			}
+			if err := output.WriteHelmValuesPatch(rsHelmPatch, helm, meta); err != nil {
 				return fmt.Errorf("write helm patch: %w", err)
 			}
 			fmt.Fprintf(os.Stderr, "✓ wrote Helm patch to %s\n", rsHelmPatch)
@@ -91,11 +278,37 @@ var benchRightsizeCmd = &cobra.Command{
 	},
 }
 
+// rightsizeDatasource returns the live client, optionally wrapped in a
+// recorder, or a replayer that serves a previous recording from disk.
+func rightsizeDatasource() (vm.Datasource, error) {
+	if rsReplayDir != "" {
+		return vm.NewReplayer(rsReplayDir)
+	}
+
+	client, err := newVMClient(rsQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if rsRecordDir != "" {
+		return vm.NewRecorder(client, rsRecordDir)
+	}
+	return client, nil
+}
+
 func init() {
 	benchCmd.AddCommand(benchRightsizeCmd)
 
-	benchRightsizeCmd.Flags().StringVar(&rsNamespace, "namespace", "microservices", "Kubernetes namespace")
-	benchRightsizeCmd.Flags().StringVar(&rsCluster, "cluster", "", "Cluster label (uw_cluster)")
+	benchRightsizeCmd.Flags().StringSliceVar(&rsNamespaces, "namespace", []string{"microservices"}, "Kubernetes namespace(s), comma-separated")
+	benchRightsizeCmd.Flags().StringVar(&rsNamespaceRegex, "namespace-regex", "", "Select namespaces matching this regex (overrides --namespace)")
+	benchRightsizeCmd.Flags().BoolVar(&rsAllNamespaces, "all-namespaces", false, "Rightsize every namespace in the cluster (overrides --namespace)")
+	benchRightsizeCmd.Flags().StringVar(&rsExcludeNs, "exclude-namespaces", "", "Skip namespaces matching this regex (e.g. 'kube-.*')")
+	benchRightsizeCmd.Flags().StringSliceVar(&rsClusters, "cluster", nil, "Cluster name(s) (values of --cluster-label), comma-separated")
+	benchRightsizeCmd.Flags().StringVar(&rsClusterRegex, "cluster-regex", "", "Rightsize every cluster matching this regex (overrides --cluster)")
+	benchRightsizeCmd.Flags().Float64Var(&rsDivergence, "divergence", service.DefaultDivergence, "Flag services whose usage differs across clusters by more than this fraction")
+	benchRightsizeCmd.Flags().StringVar(&rsClusterLabel, "cluster-label", promql.DefaultClusterLabel, "Label carrying the cluster name")
+	benchRightsizeCmd.Flags().StringSliceVar(&rsIdentityLabels, "identity-label", nil, "Extra identity labels to key results by (e.g. pod_owner,workload)")
+	benchRightsizeCmd.Flags().Var(newStringMap(&rsRequireLabels), "require-label", "External labels every query must match (e.g. env=prod)")
+	benchRightsizeCmd.Flags().StringVar(&rsGroupBy, "group-by", service.GroupByWorkload, "Key results by owning workload and container (workload) or container name only (container)")
 	benchRightsizeCmd.Flags().StringVar(&rsWindow, "window", "24h", "Time window (e.g. 24h, 7d)")
 	benchRightsizeCmd.Flags().StringVar(&rsSubStep, "sub-step", "5m", "Subquery step (e.g. 1m, 5m, 15m)")
 
@@ -105,14 +318,55 @@ func init() {
 	benchRightsizeCmd.Flags().StringVar(&rsCSVOut, "csv", "", "Write CSV to path (optional)")
 	benchRightsizeCmd.Flags().StringVar(&rsHelmPatch, "helm-patch", "", "Write Helm values patch snippet (optional)")
 
-	benchRightsizeCmd.Flags().Float64Var(&rsTargetUtil, "target-util", 0.70, "Target p95 usage/request ratio (e.g. 0.7)")
+	benchRightsizeCmd.Flags().Float64Var(&rsTargetUtil, "target-util", 0.70, "Target usage/request ratio at the sizing statistic (e.g. 0.7)")
+
+	benchRightsizeCmd.Flags().StringVar(&rsMemStat, "mem-stat", string(promql.StatP95), "Memory usage statistic to size from: p50|p90|p95|p99|p99.9|max")
+	benchRightsizeCmd.Flags().StringVar(&rsCPUStat, "cpu-stat", string(promql.StatP95), "CPU usage statistic to size from: p50|p90|p95|p99|p99.9|max")
+	benchRightsizeCmd.Flags().StringVar(&rsMode, "mode", service.ModeRatio, "Recommendation mode: ratio (scale current requests) or absolute (size from usage; also sizes containers without requests)")
+	benchRightsizeCmd.Flags().StringSliceVar(&rsStats, "stats", nil, "Extra usage statistics to show next to the sizing ones (e.g. p50,max)")
 	benchRightsizeCmd.Flags().Float64Var(&rsSafetyFactor, "safety", 1.15, "Safety multiplier for recommendation (e.g. 1.15)")
 
 	benchRightsizeCmd.Flags().Int64Var(&rsMemRoundMiB, "mem-round-mib", 64, "Round memory recommendation up to this MiB multiple")
 	benchRightsizeCmd.Flags().Int64Var(&rsCPURoundm, "cpu-round-m", 10, "Round CPU recommendation up to this millicore multiple")
 
-	benchRightsizeCmd.Flags().IntVar(&rsTopK, "topk", 50, "Limit results to top K (after ranking)")
+	benchRightsizeCmd.Flags().Float64Var(&rsMaxMemReducePct, "max-mem-reduce-pct", 0, "Cut memory requests by at most this percent per run (0 = no limit)")
+	benchRightsizeCmd.Flags().Float64Var(&rsMaxMemIncreasePct, "max-mem-increase-pct", 0, "Raise memory requests by at most this percent per run (0 = no limit)")
+	benchRightsizeCmd.Flags().Float64Var(&rsMaxCPUReducePct, "max-cpu-reduce-pct", 0, "Cut CPU requests by at most this percent per run (0 = no limit)")
+	benchRightsizeCmd.Flags().Float64Var(&rsMaxCPUIncreasePct, "max-cpu-increase-pct", 0, "Raise CPU requests by at most this percent per run (0 = no limit)")
+	benchRightsizeCmd.Flags().Int64Var(&rsMemFloorMiB, "mem-floor-mib", 0, "Never recommend less memory than this many MiB (e.g. 64; 0 = none)")
+	benchRightsizeCmd.Flags().Int64Var(&rsMemCeilingMiB, "mem-ceiling-mib", 0, "Never recommend more memory than this many MiB (e.g. node allocatable; 0 = none)")
+	benchRightsizeCmd.Flags().Int64Var(&rsCPUFloorm, "cpu-floor-m", 0, "Never recommend less CPU than this many millicores (e.g. 10; 0 = none)")
+	benchRightsizeCmd.Flags().Int64Var(&rsCPUCeilingm, "cpu-ceiling-m", 0, "Never recommend more CPU than this many millicores (e.g. node allocatable; 0 = none)")
+
+	benchRightsizeCmd.Flags().StringSliceVar(&rsSidecars, "sidecar", service.DefaultSidecarPatterns, "Container name patterns (RE2, full match) reported as sidecars")
+	benchRightsizeCmd.Flags().Float64Var(&rsSidecarSafety, "sidecar-safety", 1.3, "Safety multiplier for sidecar recommendations")
+
+	benchRightsizeCmd.Flags().Float64Var(&rsMemLimitRatio, "mem-limit-ratio", 1.0, "Memory limit as a multiple of the recommended request (never below peak * safety)")
+	benchRightsizeCmd.Flags().Float64Var(&rsCPULimitRatio, "cpu-limit-ratio", 2.0, "CPU limit as a multiple of the recommended request, for --cpu-limit ratio")
+	benchRightsizeCmd.Flags().StringVar(&rsCPULimit, "cpu-limit", service.CPULimitKeep, "CPU limit strategy: keep|remove|ratio")
+
+	benchRightsizeCmd.Flags().StringVar(&rsPricing, "pricing", "", "YAML pricing file (per vCPU-hour and GiB-hour rates) for savings estimates")
+	benchRightsizeCmd.Flags().StringVar(&rsPolicy, "policy", "", "YAML policy file with decision bands and overrides (default: built-in 0.60/0.90)")
+	benchRightsizeCmd.Flags().StringVar(&rsLowConfidence, "low-confidence", service.LowConfidenceKeep, "Low-confidence changes (little data, young pods, restarts): keep (downgrade to KEEP) or report (grade only)")
+
+	benchRightsizeCmd.Flags().IntVar(&rsTopK, "topk", 50, "Limit results to top K services per section (after --sort-by)")
+	benchRightsizeCmd.Flags().StringSliceVar(&rsOnly, "only", nil, "Keep rows with these memory or CPU decisions (REDUCE, KEEP, INCREASE, SKIP, SKIP_OOM, SKIP_THROTTLING); prefix with ! to drop them instead")
+	benchRightsizeCmd.Flags().StringVar(&rsContainerInclude, "container-include", "", "Keep only containers matching this regex")
+	benchRightsizeCmd.Flags().StringVar(&rsContainerExclude, "container-exclude", "", "Drop containers matching this regex")
+	benchRightsizeCmd.Flags().Int64Var(&rsMinMemDeltaMiB, "min-mem-delta-mib", 0, "Drop rows whose memory change is under this many MiB (unless the CPU minimum is met)")
+	benchRightsizeCmd.Flags().Int64Var(&rsMinCPUDeltam, "min-cpu-delta-m", 0, "Drop rows whose CPU change is under this many millicores (unless the memory minimum is met)")
+	benchRightsizeCmd.Flags().StringVar(&rsMissing, "missing", service.MissingKeep, "Rows without usage data: keep|hide|only")
+	benchRightsizeCmd.Flags().StringSliceVar(&rsSortBy, "sort-by", []string{"mem-ratio"}, "Sort keys as field[:asc|desc], e.g. savings,mem-ratio:desc (fields: "+strings.Join(service.SortFields(), ", ")+")")
 	benchRightsizeCmd.Flags().BoolVar(&rsBottom, "bottom", true, "Rank by most overprovisioned (lowest ratios). Use --bottom=false for most underprovisioned.")
+	_ = benchRightsizeCmd.Flags().MarkDeprecated("bottom", "use --sort-by mem-ratio:desc")
+
+	benchRightsizeCmd.Flags().IntVar(&rsConcurrency, "concurrency", 4, "Max PromQL queries in flight")
+	benchRightsizeCmd.Flags().DurationVar(&rsQueryTimeout, "query-timeout", 30*time.Second, "Timeout for each PromQL query")
+	benchRightsizeCmd.Flags().DurationVar(&rsTimeout, "timeout", 2*time.Minute, "Overall timeout for the run")
+
+	benchRightsizeCmd.Flags().StringVar(&rsRecordDir, "record", "", "Record every query and raw response into this directory")
+	benchRightsizeCmd.Flags().StringVar(&rsReplayDir, "replay", "", "Serve queries from a --record directory instead of the network")
+	benchRightsizeCmd.MarkFlagsMutuallyExclusive("record", "replay")
 
-	_ = benchRightsizeCmd.MarkFlagRequired("cluster")
+	benchRightsizeCmd.MarkFlagsOneRequired("cluster", "cluster-regex")
 }
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	cfgFile    string
+	cfgContext string
+)
+
+const (
+	envConfig     = "UPCTL_CONFIG"
+	envContext    = "UPCTL_CONTEXT"
+	envCluster    = "UPCTL_CLUSTER"
+	envNamespace  = "UPCTL_NAMESPACE"
+	envTargetUtil = "UPCTL_TARGET_UTIL"
+	envSafety     = "UPCTL_SAFETY"
+)
+
+// setting binds a flag to its config-file field and environment variable.
+// Settings whose flag is not defined on the running command are ignored.
+type setting struct {
+	flag string
+	env  string
+	file func(c *config.Context) string
+}
+
+var settings = []setting{
+	{"backend", envVMBackend, func(c *config.Context) string { return c.VM.Backend }},
+	{"vm-url", envVMURL, func(c *config.Context) string { return c.VM.URL }},
+	{"tenant", envVMTenant, func(c *config.Context) string { return c.VM.Tenant }},
+	{"vm-token", envVMToken, func(c *config.Context) string { return c.VM.Token }},
+	{"vm-user", envVMUser, func(c *config.Context) string { return c.VM.Username }},
+	{"vm-password", envVMPassword, func(c *config.Context) string { return c.VM.Password }},
+	{"vm-retries", envVMRetries, func(c *config.Context) string { return intPtrString(c.VM.Retries) }},
+	{"partial", envVMPartial, func(c *config.Context) string { return c.VM.Partial }},
+	{"cluster", envCluster, func(c *config.Context) string { return c.Cluster }},
+	{"cluster-label", "", func(c *config.Context) string { return c.Labels.Cluster }},
+	{"identity-label", "", func(c *config.Context) string { return strings.Join(c.Labels.Identity, ",") }},
+	{"require-label", "", func(c *config.Context) string { return mapString(c.Labels.Required) }},
+	{"group-by", "", func(c *config.Context) string { return c.Labels.GroupBy }},
+	{"namespace", envNamespace, func(c *config.Context) string { return c.Namespace }},
+	{"target-util", envTargetUtil, func(c *config.Context) string { return floatString(c.Thresholds.TargetUtil) }},
+	{"safety", envSafety, func(c *config.Context) string { return floatString(c.Thresholds.SafetyFactor) }},
+	{"mem-round-mib", "", func(c *config.Context) string { return intString(c.Thresholds.MemRoundMiB) }},
+	{"cpu-round-m", "", func(c *config.Context) string { return intString(c.Thresholds.CPURoundm) }},
+	{"max-mem-reduce-pct", "", func(c *config.Context) string { return floatString(c.Thresholds.MaxMemReducePct) }},
+	{"max-mem-increase-pct", "", func(c *config.Context) string { return floatString(c.Thresholds.MaxMemIncreasePct) }},
+	{"max-cpu-reduce-pct", "", func(c *config.Context) string { return floatString(c.Thresholds.MaxCPUReducePct) }},
+	{"max-cpu-increase-pct", "", func(c *config.Context) string { return floatString(c.Thresholds.MaxCPUIncreasePct) }},
+	{"mem-floor-mib", "", func(c *config.Context) string { return intString(c.Thresholds.MemFloorMiB) }},
+	{"mem-ceiling-mib", "", func(c *config.Context) string { return intString(c.Thresholds.MemCeilingMiB) }},
+	{"cpu-floor-m", "", func(c *config.Context) string { return intString(c.Thresholds.CPUFloorm) }},
+	{"cpu-ceiling-m", "", func(c *config.Context) string { return intString(c.Thresholds.CPUCeilingm) }},
+	{"mode", "", func(c *config.Context) string { return c.Thresholds.Mode }},
+	{"sort-by", "", func(c *config.Context) string { return strings.Join(c.SortBy, ",") }},
+	{"pricing", "", func(c *config.Context) string { return c.Pricing }},
+	{"mem-stat", "", func(c *config.Context) string { return c.Thresholds.MemStat }},
+	{"cpu-stat", "", func(c *config.Context) string { return c.Thresholds.CPUStat }},
+	{"stats", "", func(c *config.Context) string { return strings.Join(c.Thresholds.Stats, ",") }},
+	{"sidecar", "", func(c *config.Context) string { return strings.Join(c.Sidecars, ",") }},
+	{"policy", "", func(c *config.Context) string { return c.Policy }},
+	{"low-confidence", "", func(c *config.Context) string { return c.Thresholds.LowConfidence }},
+	{"mem-limit-ratio", "", func(c *config.Context) string { return floatString(c.Thresholds.MemLimitRatio) }},
+	{"cpu-limit-ratio", "", func(c *config.Context) string { return floatString(c.Thresholds.CPULimitRatio) }},
+	{"cpu-limit", "", func(c *config.Context) string { return c.Thresholds.CPULimit }},
+	{"sidecar-safety", "", func(c *config.Context) string { return floatString(c.Thresholds.SidecarSafety) }},
+}
+
+func floatString(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func intString(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func intPtrString(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+// mapString renders a map as sorted k=v pairs for a StringToString flag.
+func mapString(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + m[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+func configPath() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	if v := os.Getenv(envConfig); v != "" {
+		return v
+	}
+	return config.DefaultPath()
+}
+
+// applyConfig resolves every bound flag as env > flag > config context > default.
+func applyConfig(cmd *cobra.Command) error {
+	file, err := config.Load(configPath())
+	if err != nil {
+		return err
+	}
+
+	name := cfgContext
+	if name == "" {
+		name = os.Getenv(envContext)
+	}
+	ctx, err := file.Context(name)
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	for _, s := range settings {
+		f := flags.Lookup(s.flag)
+		if f == nil {
+			continue
+		}
+		if ctx != nil && !f.Changed {
+			if v := s.file(ctx); v != "" {
+				if err := setFlag(flags, f, v); err != nil {
+					return fmt.Errorf("config context: %w", err)
+				}
+			}
+		}
+		if s.env == "" {
+			continue
+		}
+		if v := os.Getenv(s.env); v != "" {
+			if err := setFlag(flags, f, v); err != nil {
+				return fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Headers merge instead of replacing: file < flag < env for the same name.
+	var headers []string
+	if ctx != nil {
+		names := make([]string, 0, len(ctx.VM.Headers))
+		for k := range ctx.VM.Headers {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			headers = append(headers, k+"="+ctx.VM.Headers[k])
+		}
+	}
+	headers = append(headers, vmHeaders...)
+	// One header per line: values may contain commas
+	if v := os.Getenv(envVMHeaders); v != "" {
+		headers = append(headers, strings.Split(v, "\n")...)
+	}
+	vmHeaders = headers
+
+	return nil
+}
+
+// setFlag overrides a flag with a file or env value. List flags are replaced
+// as a whole: Set would append to a value given on the command line.
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, v string) error {
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		if err := sv.Replace(strings.Split(v, ",")); err != nil {
+			return fmt.Errorf("--%s: %w", f.Name, err)
+		}
+		f.Changed = true
+		return nil
+	}
+	if err := flags.Set(f.Name, v); err != nil {
+		return fmt.Errorf("--%s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// stringMap is a key=value flag like pflag's StringToString (pairs given
+// again are merged), which also implements pflag.SliceValue so setFlag can
+// replace it.
+type stringMap struct {
+	value   *map[string]string
+	changed bool
+}
+
+func newStringMap(p *map[string]string) *stringMap {
+	return &stringMap{value: p}
+}
+
+func (m *stringMap) Set(val string) error {
+	if !m.changed {
+		return m.Replace(strings.Split(val, ","))
+	}
+	for _, pair := range strings.Split(val, ",") {
+		if err := m.Append(pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *stringMap) Append(pair string) error {
+	k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+	if !ok {
+		return fmt.Errorf("%s must be formatted as key=value", pair)
+	}
+	if *m.value == nil {
+		*m.value = map[string]string{}
+	}
+	(*m.value)[k] = v
+	m.changed = true
+	return nil
+}
+
+func (m *stringMap) Replace(pairs []string) error {
+	*m.value = map[string]string{}
+	for _, pair := range pairs {
+		if err := m.Append(pair); err != nil {
+			return err
+		}
+	}
+	m.changed = true
+	return nil
+}
+
+func (m *stringMap) GetSlice() []string {
+	if len(*m.value) == 0 {
+		return nil
+	}
+	return strings.Split(mapString(*m.value), ",")
+}
+
+func (m *stringMap) Type() string { return "stringToString" }
+
+// String is empty for an empty map so help shows no default.
+func (m *stringMap) String() string {
+	if len(*m.value) == 0 {
+		return ""
+	}
+	return "[" + mapString(*m.value) + "]"
+}
+
+// ---------------------------------------------------------------------
+// upctl config ...
+// ---------------------------------------------------------------------
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage upctl config file contexts",
+	// The config subcommands read and repair the file themselves, so they
+	// must not fail on a context that doesn't resolve.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+var configUseContextCmd = &cobra.Command{
+	Use:   "use-context NAME",
+	Short: "Set the current-context in the config file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configPath()
+		file, err := config.Load(path)
+		if err != nil {
+			return err
+		}
+		if _, ok := file.Contexts[args[0]]; !ok {
+			return fmt.Errorf("context %q not found in %s", args[0], path)
+		}
+		file.CurrentContext = args[0]
+		if err := file.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to context %q.\n", args[0])
+		return nil
+	},
+}
+
+var configGetContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List contexts defined in the config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := config.Load(configPath())
+		if err != nil {
+			return err
+		}
+		for _, n := range file.Names() {
+			marker := " "
+			if n == file.CurrentContext {
+				marker = "*"
+			}
+			c := file.Contexts[n]
+			url := ""
+			if c != nil {
+				url = c.VM.URL
+			}
+			fmt.Printf("%s %-20s %s\n", marker, n, url)
+		}
+		return nil
+	},
+}
+
+var configCurrentContextCmd = &cobra.Command{
+	Use:   "current-context",
+	Short: "Print the current-context",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := config.Load(configPath())
+		if err != nil {
+			return err
+		}
+		if file.CurrentContext == "" {
+			return fmt.Errorf("current-context is not set")
+		}
+		fmt.Println(file.CurrentContext)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configUseContextCmd)
+	configCmd.AddCommand(configGetContextsCmd)
+	configCmd.AddCommand(configCurrentContextCmd)
+}
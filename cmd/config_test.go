@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newConfigTestCmd returns a command with the list flags applyConfig
+// resolves, reading the config file at path.
+func newConfigTestCmd(t *testing.T, path string) (*cobra.Command, *[]string, *[]string, *map[string]string) {
+	t.Helper()
+
+	old := cfgFile
+	cfgFile = path
+	t.Cleanup(func() { cfgFile = old })
+	t.Setenv(envContext, "")
+
+	var clusters, namespaces []string
+	var required map[string]string
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringSliceVar(&clusters, "cluster", nil, "")
+	cmd.Flags().StringSliceVar(&namespaces, "namespace", []string{"microservices"}, "")
+	cmd.Flags().Var(newStringMap(&required), "require-label", "")
+	return cmd, &clusters, &namespaces, &required
+}
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testConfig = `current-context: prod
+contexts:
+  prod:
+    cluster: a
+    namespace: shop
+    labels:
+      required:
+        env: prod
+`
+
+func TestApplyConfigEnvReplacesListFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		clus  []string
+		nss   []string
+		label map[string]string
+	}{
+		{
+			name:  "file",
+			clus:  []string{"a"},
+			nss:   []string{"shop"},
+			label: map[string]string{"env": "prod"},
+		},
+		{
+			name:  "flag over file",
+			args:  []string{"--cluster", "b", "--require-label", "env=dev"},
+			clus:  []string{"b"},
+			nss:   []string{"shop"},
+			label: map[string]string{"env": "dev"},
+		},
+		{
+			name:  "env over file",
+			env:   map[string]string{envCluster: "b", envNamespace: "x"},
+			clus:  []string{"b"},
+			nss:   []string{"x"},
+			label: map[string]string{"env": "prod"},
+		},
+		{
+			name:  "env over flag",
+			args:  []string{"--cluster", "c", "--namespace", "y"},
+			env:   map[string]string{envCluster: "b", envNamespace: "x,z"},
+			clus:  []string{"b"},
+			nss:   []string{"x", "z"},
+			label: map[string]string{"env": "prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, clusters, namespaces, required := newConfigTestCmd(t, writeConfig(t, testConfig))
+			t.Setenv(envCluster, "")
+			t.Setenv(envNamespace, "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyConfig(cmd); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(*clusters, tt.clus) {
+				t.Errorf("clusters = %q, want %q", *clusters, tt.clus)
+			}
+			if !slices.Equal(*namespaces, tt.nss) {
+				t.Errorf("namespaces = %q, want %q", *namespaces, tt.nss)
+			}
+			if mapString(*required) != mapString(tt.label) {
+				t.Errorf("required labels = %v, want %v", *required, tt.label)
+			}
+		})
+	}
+}
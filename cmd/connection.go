@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+)
+
+// Connection flags shared by every command (see root.go).
+// Values are resolved against the config file and UPCTL_* env vars in applyConfig.
+var (
+	vmBackend  string
+	vmURL      string
+	vmTenant   string
+	vmToken    string
+	vmUser     string
+	vmPassword string
+	vmHeaders  []string
+	vmRetries  int
+	vmPartial  string
+)
+
+const (
+	envVMBackend  = "UPCTL_BACKEND"
+	envVMURL      = "UPCTL_VM_URL"
+	envVMTenant   = "UPCTL_TENANT"
+	envVMToken    = "UPCTL_VM_TOKEN"
+	envVMUser     = "UPCTL_VM_USER"
+	envVMPassword = "UPCTL_VM_PASSWORD"
+	envVMHeaders  = "UPCTL_VM_HEADERS" // newline-separated Name=value pairs
+	envVMRetries  = "UPCTL_VM_RETRIES"
+	envVMPartial  = "UPCTL_VM_PARTIAL"
+)
+
+// parseHeaders accepts "Name=value" or "Name: value", split at whichever
+// separator comes first, so values may contain either (e.g. base64 "==").
+func parseHeaders(raw []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, h := range raw {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		i := strings.IndexAny(h, "=:")
+		ok := i >= 0
+		name, value := h, ""
+		if ok {
+			name, value = h[:i], h[i+1:]
+		}
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (want Name=value)", h)
+		}
+		out[name] = strings.TrimSpace(value)
+	}
+	return out, nil
+}
+
+// newVMClient builds a client from the resolved connection flags.
+// timeout bounds each HTTP request (0 = client default).
+func newVMClient(timeout time.Duration) (*vm.Client, error) {
+	headers, err := parseHeaders(vmHeaders)
+	if err != nil {
+		return nil, err
+	}
+	if vmURL == "" && vm.Backend(vmBackend) != vm.BackendVMCluster {
+		return nil, fmt.Errorf("--backend %s requires --vm-url (or %s)", vmBackend, envVMURL)
+	}
+
+	return vm.NewClient(vm.Config{
+		Backend:     vm.Backend(vmBackend),
+		BaseURL:     vmURL,
+		Tenant:      vmTenant,
+		BearerToken: vmToken,
+		Username:    vmUser,
+		Password:    vmPassword,
+		Headers:     headers,
+		Timeout:     timeout,
+		MaxRetries:  vmRetries,
+		Partial:     vm.PartialPolicy(vmPartial),
+	})
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/output"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	polFile string
+	polAll  bool
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Work with decision policy files",
+}
+
+var policyTestCmd = &cobra.Command{
+	Use:   "test RESULTS.json",
+	Short: "Evaluate a policy's rules against a saved `bench rightsize --format json` result",
+	Long: `Loads and validates the policy, then applies its rules to every result in
+the file and shows which decisions the rules would change. No queries are
+made.
+
+Rules are evaluated against the built-in decisions, so the file must come
+from a run whose policy had no rules; files already rewritten by rules are
+rejected.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if polFile == "" {
+			return fmt.Errorf("--policy is required")
+		}
+		pol, err := policy.Load(polFile)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		results, meta, err := output.ReadJSON(f)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", args[0], err)
+		}
+		// Rows written before meta.policy.rules existed still name their rules
+		rewritten := slices.ContainsFunc(results, func(r model.RightsizeResult) bool { return len(r.PolicyRules) > 0 })
+		if meta.Policy.Rules > 0 || rewritten {
+			return fmt.Errorf("%s was produced with policy rules (policy %s); re-run bench rightsize with a policy without rules", args[0], meta.Policy.Name)
+		}
+
+		var changes []output.RuleChange
+		for _, before := range results {
+			after := before
+			after.PolicyRules = nil
+			if err := pol.Apply(&after); err != nil {
+				return fmt.Errorf("%s/%s: %w", before.Namespace, before.Container, err)
+			}
+			if len(after.PolicyRules) > 0 || polAll {
+				changes = append(changes, output.RuleChange{Before: before, After: after})
+			}
+		}
+
+		if len(changes) > 0 {
+			output.RenderRuleChanges(changes)
+		}
+		fmt.Printf("policy %s: rules matched %d of %d results\n", pol.Meta.Name, countMatched(changes), len(results))
+		return nil
+	},
+}
+
+func countMatched(changes []output.RuleChange) int {
+	n := 0
+	for _, c := range changes {
+		if len(c.After.PolicyRules) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyTestCmd)
+
+	policyTestCmd.Flags().StringVar(&polFile, "policy", "", "YAML policy file to test")
+	policyTestCmd.Flags().BoolVar(&polAll, "all", false, "Show every result, not only those a rule matched")
+}
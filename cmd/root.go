@@ -6,22 +6,26 @@ package cmd
 import (
 	"os"
 
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/config"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
 	"github.com/spf13/cobra"
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "upctl",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	// Run: func(cmd *cobra.Command, args []string) { },
+	Short: "Rightsizing and health checks for Kubernetes workloads backed by VictoriaMetrics",
+	Long: `upctl queries VictoriaMetrics (or any Prometheus-compatible API: Prometheus,
+Thanos Query, Grafana Mimir) for container usage and recommends resource requests.
+
+Settings are resolved in this order (highest wins):
+  1. UPCTL_* environment variables
+  2. command-line flags
+  3. the selected context in the config file (--config, --context)
+  4. built-in defaults`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return applyConfig(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -34,13 +38,17 @@ func Execute() {
 }
 
 func init() {
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
-
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cloud-monitoring-sentinel.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	pf := rootCmd.PersistentFlags()
+	pf.StringVar(&cfgFile, "config", "", "Config file (env "+envConfig+", default "+config.DefaultPath()+")")
+	pf.StringVar(&cfgContext, "context", "", "Config context to use (env "+envContext+", default current-context)")
+
+	pf.StringVar(&vmBackend, "backend", string(vm.BackendVMCluster), "Query backend: vm-cluster|vm-single|prometheus|thanos|mimir (env "+envVMBackend+")")
+	pf.StringVar(&vmURL, "vm-url", "", "Query endpoint base URL, required unless --backend is vm-cluster (env "+envVMURL+", default "+vm.DefaultBaseURL+" for vm-cluster)")
+	pf.StringVar(&vmTenant, "tenant", "", "Tenant: accountID[:projectID] for vm-cluster (default 0), X-Scope-OrgID for mimir (env "+envVMTenant+")")
+	pf.StringVar(&vmToken, "vm-token", "", "Bearer token for the query endpoint (env "+envVMToken+")")
+	pf.StringVar(&vmUser, "vm-user", "", "Basic auth username for the query endpoint (env "+envVMUser+")")
+	pf.StringVar(&vmPassword, "vm-password", "", "Basic auth password for the query endpoint (env "+envVMPassword+")")
+	pf.IntVar(&vmRetries, "vm-retries", 3, "Retries for 429/502/503/504 and connection resets (env "+envVMRetries+")")
+	pf.StringVar(&vmPartial, "partial", string(vm.PartialReject), "Partial response policy: reject|warn|accept (env "+envVMPartial+")")
+	pf.StringArrayVar(&vmHeaders, "vm-header", nil, "Extra request header Name=value, repeatable (env "+envVMHeaders+", one per line)")
 }
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the on-disk upctl configuration (~/.config/upctl/config.yaml).
+//
+//	current-context: prod
+//	contexts:
+//	  prod:
+//	    vm:
+//	      url: http://vmselect.example:8481
+//	      tenant: "0"
+//	    cluster: prod-eu-1
+//	    namespace: microservices
+//	    labels:
+//	      cluster: uw_cluster
+//	    thresholds:
+//	      target-util: 0.7
+//	      safety: 1.15
+type File struct {
+	CurrentContext string              `yaml:"current-context,omitempty"`
+	Contexts       map[string]*Context `yaml:"contexts,omitempty"`
+}
+
+// Context is a named set of defaults. Every field is optional;
+// unset fields fall back to the flag defaults.
+type Context struct {
+	VM         VM         `yaml:"vm,omitempty"`
+	Cluster    string     `yaml:"cluster,omitempty"`
+	Namespace  string     `yaml:"namespace,omitempty"`
+	Thresholds Thresholds `yaml:"thresholds,omitempty"`
+	Labels     Labels     `yaml:"labels,omitempty"`
+	Sidecars   []string   `yaml:"sidecars,omitempty"` // sidecar container name patterns
+	Policy     string     `yaml:"policy,omitempty"`   // decision policy file
+	Pricing    string     `yaml:"pricing,omitempty"`  // pricing file for savings estimates
+	SortBy     []string   `yaml:"sort-by,omitempty"`  // field[:asc|desc] sort keys
+}
+
+// Labels is the label schema of the context's TSDB.
+type Labels struct {
+	Cluster  string            `yaml:"cluster,omitempty"`  // default uw_cluster
+	Identity []string          `yaml:"identity,omitempty"` // extra identity labels
+	Required map[string]string `yaml:"required,omitempty"` // external labels every query must match
+	GroupBy  string            `yaml:"group-by,omitempty"` // workload|container
+}
+
+type VM struct {
+	Backend  string            `yaml:"backend,omitempty"` // vm-cluster|vm-single|prometheus|thanos|mimir
+	URL      string            `yaml:"url,omitempty"`
+	Tenant   string            `yaml:"tenant,omitempty"`
+	Token    string            `yaml:"token,omitempty"`
+	Username string            `yaml:"username,omitempty"`
+	Password string            `yaml:"password,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Retries  *int              `yaml:"retries,omitempty"`
+	Partial  string            `yaml:"partial,omitempty"` // reject|warn|accept
+}
+
+type Thresholds struct {
+	TargetUtil   *float64 `yaml:"target-util,omitempty"`
+	SafetyFactor *float64 `yaml:"safety,omitempty"`
+	MemRoundMiB  *int64   `yaml:"mem-round-mib,omitempty"`
+	CPURoundm    *int64   `yaml:"cpu-round-m,omitempty"`
+
+	// Guardrails: largest change per run (percent) and absolute bounds
+	MaxMemReducePct   *float64 `yaml:"max-mem-reduce-pct,omitempty"`
+	MaxMemIncreasePct *float64 `yaml:"max-mem-increase-pct,omitempty"`
+	MaxCPUReducePct   *float64 `yaml:"max-cpu-reduce-pct,omitempty"`
+	MaxCPUIncreasePct *float64 `yaml:"max-cpu-increase-pct,omitempty"`
+	MemFloorMiB       *int64   `yaml:"mem-floor-mib,omitempty"`
+	MemCeilingMiB     *int64   `yaml:"mem-ceiling-mib,omitempty"`
+	CPUFloorm         *int64   `yaml:"cpu-floor-m,omitempty"`
+	CPUCeilingm       *int64   `yaml:"cpu-ceiling-m,omitempty"`
+
+	Mode string `yaml:"mode,omitempty"` // ratio|absolute
+
+	// Usage statistics to size from (p50|p90|p95|p99|max) and extra ones to show
+	MemStat string   `yaml:"mem-stat,omitempty"`
+	CPUStat string   `yaml:"cpu-stat,omitempty"`
+	Stats   []string `yaml:"stats,omitempty"`
+
+	SidecarSafety *float64 `yaml:"sidecar-safety,omitempty"`
+
+	LowConfidence string `yaml:"low-confidence,omitempty"` // keep|report
+
+	MemLimitRatio *float64 `yaml:"mem-limit-ratio,omitempty"`
+	CPULimitRatio *float64 `yaml:"cpu-limit-ratio,omitempty"`
+	CPULimit      string   `yaml:"cpu-limit,omitempty"` // keep|remove|ratio
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/upctl/config.yaml, falling back to ~/.config.
+func DefaultPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "upctl", "config.yaml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".config", "upctl", "config.yaml")
+	}
+	return filepath.Join(home, ".config", "upctl", "config.yaml")
+}
+
+// Load reads the config file. A missing file yields an empty config. A
+// current-context naming an undefined context is not an error here; it only
+// fails when Context resolves it, so `config use-context` can repair it.
+func Load(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{Contexts: map[string]*Context{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	if err := yaml.Unmarshal(raw, f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Contexts == nil {
+		f.Contexts = map[string]*Context{}
+	}
+	return f, nil
+}
+
+// Save writes the config file (0600, since it may hold credentials).
+func (f *File) Save(path string) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
+
+// Context resolves a context by name; an empty name selects current-context.
+// It returns nil (no error) when no context is selected at all.
+func (f *File) Context(name string) (*Context, error) {
+	if name == "" {
+		name = f.CurrentContext
+	}
+	if name == "" {
+		return nil, nil
+	}
+	c, ok := f.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found", name)
+	}
+	if c == nil {
+		c = &Context{}
+	}
+	return c, nil
+}
+
+// Names returns the context names in sorted order.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Contexts))
+	for n := range f.Contexts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -17,8 +17,89 @@ type RightsizeMeta struct {
 	TargetUtil   float64 `json:"target_util"`
 	SafetyFactor float64 `json:"safety_factor"`
 	SubqueryStep string  `json:"subquery_step"`
+
+	// Usage statistics (e.g. p95, max) the ratios are taken at, and extra
+	// statistics reported alongside them
+	MemStat    string   `json:"mem_stat"`
+	CPUStat    string   `json:"cpu_stat"`
+	ExtraStats []string `json:"extra_stats,omitempty"`
+
+	// Recommendation mode: ratio (scale current requests) or absolute
+	// (size from absolute usage, so containers without requests are sized)
+	Mode string `json:"mode"`
+
+	// All clusters analysed (Cluster is their comma-joined form)
+	Clusters []string `json:"clusters"`
+
+	ClusterLabel   string   `json:"cluster_label"`
+	IdentityLabels []string `json:"identity_labels"`
+	GroupBy        string   `json:"group_by"` // workload|container
+
+	// Result ordering, as field:asc|desc keys
+	SortBy []string `json:"sort_by"`
+
+	// Guardrails bounding recommendations (step limits, floors, ceilings)
+	Guardrails []string `json:"guardrails,omitempty"`
+
+	// Filters applied to the results and how many rows they dropped
+	Filters  []string `json:"filters,omitempty"`
+	Filtered int      `json:"filtered"`
+
+	// Limits: limit-to-request ratios and CPU limit strategy (keep|remove|ratio)
+	MemLimitRatio    float64 `json:"mem_limit_ratio"`
+	CPULimitRatio    float64 `json:"cpu_limit_ratio"`
+	CPULimitStrategy string  `json:"cpu_limit_strategy"`
+
+	// Decision policy (bands and overrides)
+	Policy PolicyMeta `json:"policy"`
+
+	// What happens to low-confidence changes: keep (downgraded to KEEP)
+	// or report (graded only)
+	LowConfidence string `json:"low_confidence"`
+
+	// Pricing model behind the savings estimates (nil: no estimates)
+	Pricing *PricingMeta `json:"pricing,omitempty"`
+
+	// Container name patterns treated as sidecars
+	SidecarPatterns []string `json:"sidecar_patterns"`
+
+	// Optional signals (OOM, throttling, JVM) that could not be fetched;
+	// their checks were skipped for every result.
+	FailedSignals []SignalError `json:"failed_signals,omitempty"`
+
+	// Warnings returned by the query API (e.g. accepted partial responses)
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PolicyMeta identifies the decision policy that produced a report.
+type PolicyMeta struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`           // policy file path, or "builtin"
+	Digest string `json:"digest,omitempty"` // sha256 of the policy file
+	Rules  int    `json:"rules,omitempty"`  // number of custom decision rules
+}
+
+// PricingMeta identifies the pricing model of the savings estimates.
+type PricingMeta struct {
+	Source   string `json:"source"`   // pricing file path
+	Digest   string `json:"digest"`   // sha256 of the pricing file
+	Capacity string `json:"capacity"` // default capacity type: on-demand|spot
+}
+
+type SignalError struct {
+	Signal string `json:"signal"`
+	Error  string `json:"error"`
 }
 
+// ContainerRole separates the main app from helpers with their own policy.
+type ContainerRole string
+
+const (
+	RoleApp     ContainerRole = "app"
+	RoleSidecar ContainerRole = "sidecar" // matched by name pattern
+	RoleInit    ContainerRole = "init"    // kube_pod_init_container_*; sized to peak
+)
+
 type CPUDecision string
 type MemoryDecision string
 type JVMDecision string
@@ -28,6 +109,7 @@ const (
 	CPUKeep           CPUDecision = "KEEP"
 	CPUIncrease       CPUDecision = "INCREASE"
 	CPUSkipThrottling CPUDecision = "SKIP_THROTTLING"
+	CPUSkip           CPUDecision = "SKIP" // by a policy rule
 )
 
 const (
@@ -35,6 +117,7 @@ const (
 	MemKeep     MemoryDecision = "KEEP"
 	MemIncrease MemoryDecision = "INCREASE"
 	MemSkipOOM  MemoryDecision = "SKIP_OOM"
+	MemSkip     MemoryDecision = "SKIP" // by a policy rule
 )
 
 const (
@@ -42,39 +125,150 @@ const (
 	JVMIncrease JVMDecision = "INCREASE"
 )
 
+// Confidence grades how much data a recommendation rests on.
+type Confidence string
+
+const (
+	ConfidenceHigh   Confidence = "high"
+	ConfidenceMedium Confidence = "medium"
+	ConfidenceLow    Confidence = "low"
+)
+
 type RightsizeResult struct {
-	Namespace string
-	Cluster   string
-	Container string
+	Namespace string        `json:"namespace"`
+	Cluster   string        `json:"cluster"`
+	Container string        `json:"container"`
+	Role      ContainerRole `json:"role"`
+
+	// Owning workload (e.g. Deployment/api); empty when grouping by container
+	WorkloadKind string `json:"workload_kind,omitempty"`
+	Workload     string `json:"workload,omitempty"`
+
+	// Extra identity labels from the label schema (e.g. pod_owner, workload)
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// usage/request at the sizing statistic (RightsizeMeta.MemStat/CPUStat);
+	// peak usage/request for init containers
+	MemUsageRatio float64 `json:"mem_usage_ratio"`
+	CpuUsageRatio float64 `json:"cpu_usage_ratio"`
+
+	// usage/request at each extra statistic, keyed by statistic (e.g. "p50")
+	MemUsageStats map[string]float64 `json:"mem_usage_stats,omitempty"`
+	CpuUsageStats map[string]float64 `json:"cpu_usage_stats,omitempty"`
+
+	// Absolute usage at the sizing statistic (absolute mode only)
+	MemUsageBytes int64   `json:"mem_usage_bytes,omitempty"`
+	CpuUsageCores float64 `json:"cpu_usage_cores,omitempty"`
 
-	MemP95Ratio float64
-	CpuP95Ratio float64
+	MemRequestBytes int64   `json:"mem_request_bytes"`
+	CpuRequestCores float64 `json:"cpu_request_cores"`
 
-	MemRequestBytes int64
-	CpuRequestCores float64
+	// Replicas running the container: at query time, averaged over the
+	// window and at peak (workload status replicas, or pod counts)
+	Replicas     float64 `json:"replicas"`
+	ReplicasAvg  float64 `json:"replicas_avg"`
+	ReplicasPeak float64 `json:"replicas_peak"`
 
-	MemRecommendedBytes int64
-	CpuRecommendedCores float64
+	MemRecommendedBytes int64   `json:"mem_recommended_bytes"`
+	CpuRecommendedCores float64 `json:"cpu_recommended_cores"`
 
-	OOMKilled    bool
-	CPUThrottled bool
+	// Limits (0 = no limit) and the peak usage they are sized from:
+	// max working set for memory, p99.9 for CPU
+	MemLimitBytes            int64   `json:"mem_limit_bytes"`
+	MemLimitRecommendedBytes int64   `json:"mem_limit_recommended_bytes"`
+	CpuLimitCores            float64 `json:"cpu_limit_cores"`
+	CpuLimitRecommendedCores float64 `json:"cpu_limit_recommended_cores"`
+	MemPeakBytes             int64   `json:"mem_peak_bytes"`
+	CpuPeakCores             float64 `json:"cpu_peak_cores"`
 
-	JVMHeapAfterGCRatio float64
-	JVMNonHeapBytes     int64
+	OOMKilled    bool `json:"oom_killed"`
+	CPUThrottled bool `json:"cpu_throttled"`
 
-	MemoryDecision     MemoryDecision
-	CPUDecision        CPUDecision
-	JVMHeapDecision    JVMDecision
-	JVMNonHeapDecision JVMDecision
+	JVMHeapAfterGCRatio float64 `json:"jvm_heap_after_gc_ratio"`
+	JVMNonHeapBytes     int64   `json:"jvm_non_heap_bytes"`
+
+	// Data behind the recommendation: fraction of the window with usage
+	// data, age of the oldest running pod and most restarts of one pod in
+	// the window; Confidence is graded from them ("" for init containers)
+	// and ConfidenceWhy lists what lowered it
+	Coverage      float64    `json:"coverage"`
+	PodAgeHours   float64    `json:"pod_age_hours"`
+	Restarts      float64    `json:"restarts"`
+	Confidence    Confidence `json:"confidence,omitempty"`
+	ConfidenceWhy string     `json:"confidence_why,omitempty"`
+
+	MemoryDecision     MemoryDecision `json:"memory_decision"`
+	CPUDecision        CPUDecision    `json:"cpu_decision"`
+	JVMHeapDecision    JVMDecision    `json:"jvm_heap_decision"`
+	JVMNonHeapDecision JVMDecision    `json:"jvm_non_heap_decision"`
 	// Deltas (recommended - current)
-	CpuDeltaCores float64
-	MemDeltaBytes int64
+	CpuDeltaCores float64 `json:"cpu_delta_cores"`
+	MemDeltaBytes int64   `json:"mem_delta_bytes"`
+
+	// Deltas across the average replica count (negative: reclaimable)
+	CpuDeltaTotalCores float64 `json:"cpu_delta_total_cores"`
+	MemDeltaTotalBytes int64   `json:"mem_delta_total_bytes"`
 
-	// Optional dollar estimate (if pricing provided)
-	EstSavingsPerHourUSD float64
+	// Estimated savings across all replicas, negative for added cost
+	// (0 without pricing, and for init containers); PriceTier is the
+	// capacity type (and node pool) the rates came from
+	EstSavingsPerHourUSD  float64 `json:"est_savings_per_hour_usd"`
+	EstSavingsPerMonthUSD float64 `json:"est_savings_per_month_usd"`
+	PriceTier             string  `json:"price_tier,omitempty"`
+
+	// Policy overrides applied to this result (empty: policy defaults)
+	PolicyOverrides []string `json:"policy_overrides,omitempty"`
+
+	// Policy rules that changed a decision, in evaluation order
+	PolicyRules []string `json:"policy_rules,omitempty"`
 
 	// Explanations ("why")
-	CPUWhy    string
-	MemoryWhy string
-	JVMWhy    string
+	CPUWhy    string `json:"cpu_why"`
+	MemoryWhy string `json:"memory_why"`
+	JVMWhy    string `json:"jvm_why"`
+}
+
+// MergedResult is one service across clusters: recommendations are sized
+// for the worst cluster, and spreads flag clusters that behave differently.
+type MergedResult struct {
+	RightsizeResult
+
+	// Clusters the service runs in
+	Clusters []string `json:"clusters"`
+
+	// Usage spread across clusters: max/min of usage at the sizing statistic (1 = identical)
+	MemUsageSpread float64 `json:"mem_usage_spread"`
+	CpuUsageSpread float64 `json:"cpu_usage_spread"`
+
+	// Diverges is set when a spread exceeds the divergence threshold
+	Diverges bool `json:"diverges"`
+}
+
+// NamespaceTotals sums requests and recommendations over one namespace.
+type NamespaceTotals struct {
+	Namespace  string `json:"namespace"`
+	Containers int    `json:"containers"`
+
+	MemRequestBytes     int64 `json:"mem_request_bytes"`
+	MemRecommendedBytes int64 `json:"mem_recommended_bytes"`
+	MemDeltaBytes       int64 `json:"mem_delta_bytes"`
+
+	CpuRequestCores     float64 `json:"cpu_request_cores"`
+	CpuRecommendedCores float64 `json:"cpu_recommended_cores"`
+	CpuDeltaCores       float64 `json:"cpu_delta_cores"`
+
+	// Across replicas (negative: reclaimable)
+	MemDeltaTotalBytes int64   `json:"mem_delta_total_bytes"`
+	CpuDeltaTotalCores float64 `json:"cpu_delta_total_cores"`
+
+	EstSavingsPerHourUSD  float64 `json:"est_savings_per_hour_usd"`
+	EstSavingsPerMonthUSD float64 `json:"est_savings_per_month_usd"`
+}
+
+// WorkloadRef renders the owning workload as Kind/name ("" if unknown).
+func (r RightsizeResult) WorkloadRef() string {
+	if r.Workload == "" {
+		return ""
+	}
+	return r.WorkloadKind + "/" + r.Workload
 }
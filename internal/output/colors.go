@@ -8,13 +8,13 @@ import (
 func colorDecision(d string) string {
 	switch d {
 	case "REDUCE":
-		return text.FgGreen.Sprintf(d)
+		return text.FgGreen.Sprint(d)
 	case "KEEP":
-		return text.FgYellow.Sprintf(d)
+		return text.FgYellow.Sprint(d)
 	case "INCREASE":
-		return text.FgRed.Sprintf(d)
-	case "SKIP_OOM", "SKIP_THROTTLING":
-		return text.FgHiRed.Sprintf(d)
+		return text.FgRed.Sprint(d)
+	case "SKIP_OOM", "SKIP_THROTTLING", "SKIP":
+		return text.FgHiRed.Sprint(d)
 	default:
 		return d
 	}
@@ -31,3 +31,17 @@ func colorMemory(d model.MemoryDecision) string {
 func colorJVM(d model.JVMDecision) string {
 	return colorDecision(string(d))
 }
+
+// colorConfidence renders "-" for ungraded (init) containers.
+func colorConfidence(c model.Confidence) string {
+	switch c {
+	case model.ConfidenceHigh:
+		return text.FgGreen.Sprint(c)
+	case model.ConfidenceMedium:
+		return text.FgYellow.Sprint(c)
+	case model.ConfidenceLow:
+		return text.FgHiRed.Sprint(c)
+	default:
+		return "-"
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
 )
@@ -24,51 +25,147 @@ func WriteCSV(path string, results []model.RightsizeResult, meta model.Rightsize
 
 	_ = w.Write([]string{"namespace", meta.Namespace})
 	_ = w.Write([]string{"cluster", meta.Cluster})
+	_ = w.Write([]string{"group_by", meta.GroupBy})
+	_ = w.Write(append([]string{"sort_by"}, meta.SortBy...))
+	_ = w.Write(append([]string{"filters"}, meta.Filters...))
+	_ = w.Write([]string{"filtered", fmt.Sprintf("%d", meta.Filtered)})
 	_ = w.Write([]string{"window", meta.Window})
 	_ = w.Write([]string{"oom_window", meta.OOMWindow})
 	_ = w.Write([]string{"target_util", fmt.Sprintf("%f", meta.TargetUtil)})
 	_ = w.Write([]string{"safety_factor", fmt.Sprintf("%f", meta.SafetyFactor)})
+	_ = w.Write([]string{"mode", meta.Mode})
+	_ = w.Write([]string{"mem_stat", meta.MemStat})
+	_ = w.Write([]string{"cpu_stat", meta.CPUStat})
+	_ = w.Write(append([]string{"extra_stats"}, meta.ExtraStats...))
+	_ = w.Write(append([]string{"sidecar_patterns"}, meta.SidecarPatterns...))
+	_ = w.Write([]string{"policy", meta.Policy.Name, meta.Policy.Source, meta.Policy.Digest})
+	_ = w.Write([]string{"low_confidence", meta.LowConfidence})
+	_ = w.Write(append([]string{"guardrails"}, meta.Guardrails...))
+	_ = w.Write([]string{"mem_limit_ratio", fmt.Sprintf("%f", meta.MemLimitRatio)})
+	_ = w.Write([]string{"cpu_limit_ratio", fmt.Sprintf("%f", meta.CPULimitRatio)})
+	_ = w.Write([]string{"cpu_limit_strategy", meta.CPULimitStrategy})
+	if meta.Pricing != nil {
+		_ = w.Write([]string{"pricing", meta.Pricing.Source, meta.Pricing.Capacity, meta.Pricing.Digest})
+	}
+	for _, f := range meta.FailedSignals {
+		_ = w.Write([]string{"failed_signal", f.Signal, f.Error})
+	}
+	for _, warning := range meta.Warnings {
+		_ = w.Write([]string{"warning", warning})
+	}
 	_ = w.Write([]string{}) // blank line
 
 	// ------------------------------------------------------------------
 	// Header
 	// ------------------------------------------------------------------
 
-	_ = w.Write([]string{
+	// Extra statistics go last, so the fixed columns keep their positions
+	header := []string{
+		"namespace",
+		"cluster",
+		"workload_kind",
+		"workload",
 		"container",
-		"mem_p95_ratio",
-		"cpu_p95_ratio",
+		"role",
+		"mem_usage_ratio",
+		"cpu_usage_ratio",
 		"mem_request_bytes",
 		"mem_recommended_bytes",
 		"cpu_request_cores",
 		"cpu_recommended_cores",
+		"mem_limit_bytes",
+		"mem_limit_recommended_bytes",
+		"cpu_limit_cores",
+		"cpu_limit_recommended_cores",
+		"mem_peak_bytes",
+		"cpu_peak_cores",
 		"oom_killed",
 		"cpu_decision",
 		"memory_decision",
 		"jvm_heap_decision",
 		"jvm_non_heap_decision",
-	})
+		"policy_overrides",
+		"mem_usage_bytes",
+		"cpu_usage_cores",
+		"replicas",
+		"replicas_avg",
+		"replicas_peak",
+		"mem_delta_total_bytes",
+		"cpu_delta_total_cores",
+		"price_tier",
+		"est_savings_per_hour_usd",
+		"est_savings_per_month_usd",
+		"coverage",
+		"pod_age_hours",
+		"restarts",
+		"confidence",
+		"confidence_why",
+	}
+	for _, st := range meta.ExtraStats {
+		header = append(header, "mem_"+st+"_ratio", "cpu_"+st+"_ratio")
+	}
+	_ = w.Write(header)
 
 	// ------------------------------------------------------------------
 	// Rows
 	// ------------------------------------------------------------------
 
 	for _, r := range results {
-		_ = w.Write([]string{
+		row := []string{
+			r.Namespace,
+			r.Cluster,
+			r.WorkloadKind,
+			r.Workload,
 			r.Container,
-			fmt.Sprintf("%f", r.MemP95Ratio),
-			fmt.Sprintf("%f", r.CpuP95Ratio),
+			string(r.Role),
+			fmt.Sprintf("%f", r.MemUsageRatio),
+			fmt.Sprintf("%f", r.CpuUsageRatio),
 			fmt.Sprintf("%d", r.MemRequestBytes),
 			fmt.Sprintf("%d", r.MemRecommendedBytes),
 			fmt.Sprintf("%f", r.CpuRequestCores),
 			fmt.Sprintf("%f", r.CpuRecommendedCores),
+			fmt.Sprintf("%d", r.MemLimitBytes),
+			fmt.Sprintf("%d", r.MemLimitRecommendedBytes),
+			fmt.Sprintf("%f", r.CpuLimitCores),
+			fmt.Sprintf("%f", r.CpuLimitRecommendedCores),
+			fmt.Sprintf("%d", r.MemPeakBytes),
+			fmt.Sprintf("%f", r.CpuPeakCores),
 			fmt.Sprintf("%t", r.OOMKilled),
 			string(r.CPUDecision),
 			string(r.MemoryDecision),
 			string(r.JVMHeapDecision),
 			string(r.JVMNonHeapDecision),
-		})
+			strings.Join(r.PolicyOverrides, ";"),
+			fmt.Sprintf("%d", r.MemUsageBytes),
+			fmt.Sprintf("%f", r.CpuUsageCores),
+			fmt.Sprintf("%g", r.Replicas),
+			fmt.Sprintf("%f", r.ReplicasAvg),
+			fmt.Sprintf("%g", r.ReplicasPeak),
+			fmt.Sprintf("%d", r.MemDeltaTotalBytes),
+			fmt.Sprintf("%f", r.CpuDeltaTotalCores),
+			r.PriceTier,
+			fmt.Sprintf("%f", r.EstSavingsPerHourUSD),
+			fmt.Sprintf("%f", r.EstSavingsPerMonthUSD),
+			fmt.Sprintf("%f", r.Coverage),
+			fmt.Sprintf("%f", r.PodAgeHours),
+			fmt.Sprintf("%g", r.Restarts),
+			string(r.Confidence),
+			r.ConfidenceWhy,
+		}
+		for _, st := range meta.ExtraStats {
+			row = append(row, csvStat(r.MemUsageStats, st), csvStat(r.CpuUsageStats, st))
+		}
+		_ = w.Write(row)
 	}
 
 	return w.Error()
 }
+
+// csvStat renders an extra statistic, empty when it was not fetched.
+func csvStat(stats map[string]float64, st string) string {
+	v, ok := stats[st]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%f", v)
+}
@@ -1,13 +1,14 @@
 package output
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/jedib0t/go-pretty/v6/text"
 )
 
 func formatCPUChange(req, rec float64, decision string) string {
-	if decision == "SKIP_THROTTLING" {
+	if decision == "SKIP_THROTTLING" || decision == "SKIP" {
 		return text.FgHiRed.Sprintf("⏭ SKIP")
 	}
 
@@ -26,7 +27,7 @@ func formatCPUChange(req, rec float64, decision string) string {
 func formatMemChange(reqBytes, recBytes int64, decision string) string {
 	const MiB = 1024 * 1024
 
-	if decision == "SKIP_OOM" {
+	if decision == "SKIP_OOM" || decision == "SKIP" {
 		return text.FgHiRed.Sprintf("⏭ SKIP")
 	}
 
@@ -43,3 +44,77 @@ func formatMemChange(reqBytes, recBytes int64, decision string) string {
 
 	return text.FgRed.Sprintf("↑ %.0fMi (+%.0f)", recMiB, deltaMiB)
 }
+
+// Limits show "current → recommended"; 0 means no limit.
+
+func formatMemLimit(cur, rec int64) string {
+	return formatLimit(float64(cur), float64(rec), func(v float64) string { return bytes(int64(v)) })
+}
+
+func formatCPULimit(cur, rec float64) string {
+	return formatLimit(cur, rec, func(v float64) string { return fmt.Sprintf("%.2f", v) })
+}
+
+func formatLimit(cur, rec float64, format func(float64) string) string {
+	show := func(v float64) string {
+		if v <= 0 {
+			return "none"
+		}
+		return format(v)
+	}
+	if math.Abs(rec-cur) < 0.001*math.Max(cur, 1) {
+		return text.FgYellow.Sprint(show(rec))
+	}
+	color := text.FgRed
+	if rec > 0 && (cur <= 0 || rec < cur) {
+		color = text.FgGreen
+	}
+	if rec <= 0 {
+		color = text.FgHiBlue // limit removed
+	}
+	return show(cur) + " → " + color.Sprint(show(rec))
+}
+
+// formatSavings shows a monthly estimate: savings in green, added cost in red.
+func formatSavings(usd float64) string {
+	switch {
+	case math.Abs(usd) < 0.005:
+		return text.FgYellow.Sprint("$0.00")
+	case usd > 0:
+		return text.FgGreen.Sprintf("$%.2f", usd)
+	default:
+		return text.FgRed.Sprintf("-$%.2f", -usd)
+	}
+}
+
+// formatReplicas shows average / peak replicas ("-" without replica data).
+func formatReplicas(avg, peak float64) string {
+	if avg <= 0 && peak <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f / %.0f", avg, peak)
+}
+
+// Totals across replicas: reductions in green, increases in red.
+
+func formatMemDelta(b int64) string {
+	switch {
+	case b < 0:
+		return text.FgGreen.Sprint("-" + bytes(-b))
+	case b > 0:
+		return text.FgRed.Sprint("+" + bytes(b))
+	default:
+		return text.FgYellow.Sprint("0")
+	}
+}
+
+func formatCPUDelta(c float64) string {
+	switch {
+	case c <= -0.005:
+		return text.FgGreen.Sprintf("%.2f", c)
+	case c >= 0.005:
+		return text.FgRed.Sprintf("+%.2f", c)
+	default:
+		return text.FgYellow.Sprint("0")
+	}
+}
@@ -0,0 +1,84 @@
+package output
+
+import (
+	"sort"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+type namespaceGroup struct {
+	Totals  model.NamespaceTotals
+	Results []model.RightsizeResult
+}
+
+// groupByNamespace splits ranked results per namespace (sorted by name),
+// keeping the ranking inside each group, and sums requests/recommendations.
+func groupByNamespace(results []model.RightsizeResult) []namespaceGroup {
+	idx := map[string]int{}
+	var groups []namespaceGroup
+
+	for _, r := range results {
+		i, ok := idx[r.Namespace]
+		if !ok {
+			i = len(groups)
+			idx[r.Namespace] = i
+			groups = append(groups, namespaceGroup{Totals: model.NamespaceTotals{Namespace: r.Namespace}})
+		}
+		g := &groups[i]
+		g.Results = append(g.Results, r)
+
+		t := &g.Totals
+		t.Containers++
+		t.MemRequestBytes += r.MemRequestBytes
+		t.MemRecommendedBytes += r.MemRecommendedBytes
+		t.MemDeltaBytes += r.MemDeltaBytes
+		t.CpuRequestCores += r.CpuRequestCores
+		t.CpuRecommendedCores += r.CpuRecommendedCores
+		t.CpuDeltaCores += r.CpuDeltaCores
+		t.MemDeltaTotalBytes += r.MemDeltaTotalBytes
+		t.CpuDeltaTotalCores += r.CpuDeltaTotalCores
+		t.EstSavingsPerHourUSD += r.EstSavingsPerHourUSD
+		t.EstSavingsPerMonthUSD += r.EstSavingsPerMonthUSD
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Totals.Namespace < groups[j].Totals.Namespace
+	})
+	return groups
+}
+
+func namespaceTotals(results []model.RightsizeResult) []model.NamespaceTotals {
+	groups := groupByNamespace(results)
+	out := make([]model.NamespaceTotals, len(groups))
+	for i, g := range groups {
+		out[i] = g.Totals
+	}
+	return out
+}
+
+// clusterNames returns the distinct clusters in results, sorted.
+func clusterNames(results []model.RightsizeResult) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, r := range results {
+		if !seen[r.Cluster] {
+			seen[r.Cluster] = true
+			out = append(out, r.Cluster)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// splitRoles partitions results by container role, keeping their order.
+func splitRoles(results []model.RightsizeResult) map[model.ContainerRole][]model.RightsizeResult {
+	out := map[model.ContainerRole][]model.RightsizeResult{}
+	for _, r := range results {
+		role := r.Role
+		if role == "" {
+			role = model.RoleApp
+		}
+		out[role] = append(out[role], r)
+	}
+	return out
+}
@@ -2,45 +2,142 @@ package output
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
 )
 
-func WriteHelmValuesPatch(path string, results []model.RightsizeResult) error {
+func WriteHelmValuesPatch(path string, results []model.RightsizeResult, meta model.RightsizeMeta) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	// Keep deterministic ordering
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Container < results[j].Container
-	})
-
 	// A generic values.yaml snippet keyed by "services.<name>.resources.requests"
 	// Your platform team can align charts to consume this structure.
+	// Multi-namespace runs nest it under "namespaces.<namespace>".
+	// With workloads, <name> is the workload (suffixed with its kind when
+	// workloads of different kinds share a name); workloads with several
+	// containers list them under "services.<name>.containers.<container>".
+	// Only app containers are included: sidecars are sized by their injector
+	// and init containers are reported separately.
 	_, _ = fmt.Fprintln(f, "# upctl-generated Helm values snippet")
 	_, _ = fmt.Fprintln(f, "# Merge this into your chart values (or adapt to your chart schema).")
-	_, _ = fmt.Fprintln(f, "services:")
+	_, _ = fmt.Fprintf(f, "# policy: %s\n", policyString(meta.Policy))
 
-	for _, r := range results {
-		// Skip if both are KEEP and no mem/cpu change desired (optional). For now include all.
-		cpu := cpuString(r.CpuRecommendedCores)
-		mem := memString(r.MemRecommendedBytes)
+	results = splitRoles(results)[model.RoleApp]
+
+	// Only the remove strategy (service.CPULimitRemove) deletes CPU limits
+	removeCPULimits := meta.CPULimitStrategy == "remove"
 
-		_, _ = fmt.Fprintf(f, "  %s:\n", r.Container)
-		_, _ = fmt.Fprintln(f, "    resources:")
-		_, _ = fmt.Fprintln(f, "      requests:")
-		_, _ = fmt.Fprintf(f, "        cpu: %q\n", cpu)
-		_, _ = fmt.Fprintf(f, "        memory: %q\n", mem)
+	groups := groupByNamespace(results)
+	if len(groups) <= 1 {
+		writeHelmServices(f, "", results, removeCPULimits)
+		return nil
 	}
 
+	_, _ = fmt.Fprintln(f, "namespaces:")
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(f, "  %s:\n", g.Totals.Namespace)
+		writeHelmServices(f, "    ", g.Results, removeCPULimits)
+	}
 	return nil
 }
 
+func writeHelmServices(w io.Writer, indent string, results []model.RightsizeResult, removeCPULimits bool) {
+	// Keep deterministic ordering (without reordering the caller's slice)
+	results = append([]model.RightsizeResult(nil), results...)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Workload != results[j].Workload {
+			return results[i].Workload < results[j].Workload
+		}
+		if results[i].WorkloadKind != results[j].WorkloadKind {
+			return results[i].WorkloadKind < results[j].WorkloadKind
+		}
+		return results[i].Container < results[j].Container
+	})
+
+	// Workloads of different kinds may share a name (a Deployment and a
+	// StatefulSet "api"); their keys get the kind as a suffix
+	kinds := map[string]map[string]bool{}
+	for _, r := range results {
+		if kinds[r.Workload] == nil {
+			kinds[r.Workload] = map[string]bool{}
+		}
+		kinds[r.Workload][r.WorkloadKind] = true
+	}
+
+	_, _ = fmt.Fprintln(w, indent+"services:")
+
+	for i := 0; i < len(results); {
+		r := results[i]
+		if r.Workload == "" {
+			writeHelmResources(w, indent+"  ", r.Container, r, removeCPULimits)
+			i++
+			continue
+		}
+
+		name := r.Workload
+		if len(kinds[r.Workload]) > 1 {
+			name += "-" + strings.ToLower(r.WorkloadKind)
+		}
+
+		// All containers of one workload
+		j := i + 1
+		for j < len(results) && results[j].WorkloadRef() == r.WorkloadRef() {
+			j++
+		}
+		if j-i == 1 {
+			writeHelmResources(w, indent+"  ", name, r, removeCPULimits)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s  %s:\n", indent, name)
+			_, _ = fmt.Fprintln(w, indent+"    containers:")
+			for _, c := range results[i:j] {
+				writeHelmResources(w, indent+"      ", c.Container, c, removeCPULimits)
+			}
+		}
+		i = j
+	}
+}
+
+func writeHelmResources(w io.Writer, indent, name string, r model.RightsizeResult, removeCPULimits bool) {
+	// Skip if both are KEEP and no mem/cpu change desired (optional). For now include all.
+	cpu := cpuString(r.CpuRecommendedCores)
+	mem := memString(r.MemRecommendedBytes)
+
+	_, _ = fmt.Fprintf(w, "%s%s:\n", indent, name)
+	_, _ = fmt.Fprintln(w, indent+"  resources:")
+	_, _ = fmt.Fprintln(w, indent+"    requests:")
+	_, _ = fmt.Fprintf(w, "%s      cpu: %q\n", indent, cpu)
+	_, _ = fmt.Fprintf(w, "%s      memory: %q\n", indent, mem)
+
+	// A zero CPU limit is written as null (so merging the patch drops an
+	// existing limit) only when limits are being removed; otherwise it means
+	// no limit or an unknown one, and the key is left out. So is an empty
+	// limits map, which would merge as null too.
+	var limits []string
+	switch {
+	case r.CpuLimitRecommendedCores > 0:
+		limits = append(limits, fmt.Sprintf("cpu: %q", cpuString(r.CpuLimitRecommendedCores)))
+	case removeCPULimits:
+		limits = append(limits, "cpu: null")
+	}
+	if r.MemLimitRecommendedBytes > 0 {
+		limits = append(limits, fmt.Sprintf("memory: %q", memString(r.MemLimitRecommendedBytes)))
+	}
+	if len(limits) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, indent+"    limits:")
+	for _, l := range limits {
+		_, _ = fmt.Fprintf(w, "%s      %s\n", indent, l)
+	}
+}
+
 func cpuString(cores float64) string {
 	// Convert cores to millicores for readability
 	m := int64(cores * 1000.0)
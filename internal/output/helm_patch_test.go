@@ -0,0 +1,105 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+func writeHelmPatch(t *testing.T, results []model.RightsizeResult, meta model.RightsizeMeta) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := WriteHelmValuesPatch(path, results, meta); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func workloadRow(ns, kind, workload, container string, memMiB int64, cpu float64) model.RightsizeResult {
+	return model.RightsizeResult{
+		Namespace: ns, Cluster: "c1", Container: container, Role: model.RoleApp,
+		WorkloadKind: kind, Workload: workload,
+		MemRecommendedBytes: memMiB << 20, CpuRecommendedCores: cpu,
+	}
+}
+
+func TestHelmPatchWorkloadsGolden(t *testing.T) {
+	results := []model.RightsizeResult{
+		// A Deployment and a StatefulSet share the name "api"
+		workloadRow("shop", "StatefulSet", "api", "api", 512, 0.5),
+		workloadRow("shop", "Deployment", "api", "api", 256, 0.25),
+		// Two containers of one workload
+		workloadRow("shop", "Deployment", "web", "web", 128, 0.1),
+		workloadRow("shop", "Deployment", "web", "nginx", 64, 0.05),
+		// A bare pod keyed by container
+		{Namespace: "shop", Cluster: "c1", Container: "debug", Role: model.RoleApp, MemRecommendedBytes: 32 << 20, CpuRecommendedCores: 0.01},
+		// Not app containers: left out
+		{Namespace: "shop", Cluster: "c1", Container: "istio-proxy", Role: model.RoleSidecar, MemRecommendedBytes: 64 << 20},
+		{Namespace: "shop", Cluster: "c1", Container: "migrate", Role: model.RoleInit, MemRecommendedBytes: 64 << 20},
+	}
+	golden(t, "helm_workloads.yaml", []byte(writeHelmPatch(t, results, testMeta())))
+}
+
+func TestHelmPatchNamespacesGolden(t *testing.T) {
+	results := []model.RightsizeResult{
+		workloadRow("shop", "Deployment", "api", "api", 256, 0.25),
+		workloadRow("billing", "Deployment", "api", "api", 1024, 1),
+	}
+	golden(t, "helm_namespaces.yaml", []byte(writeHelmPatch(t, results, testMeta())))
+}
+
+func TestHelmPatchLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		cpuLimit float64
+		memLimit int64
+		want     string
+	}{
+		{name: "both limits", strategy: "ratio", cpuLimit: 0.5, memLimit: 512 << 20, want: `
+      limits:
+        cpu: "500m"
+        memory: "512Mi"
+`},
+		{name: "removed cpu limit", strategy: "remove", memLimit: 512 << 20, want: `
+      limits:
+        cpu: null
+        memory: "512Mi"
+`},
+		{name: "no cpu limit kept", strategy: "keep", memLimit: 512 << 20, want: `
+      limits:
+        memory: "512Mi"
+`},
+		{name: "no limits", strategy: "keep", want: `
+        memory: "128Mi"
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := testMeta()
+			meta.CPULimitStrategy = tt.strategy
+			r := model.RightsizeResult{
+				Namespace: "shop", Container: "api", Role: model.RoleApp,
+				MemRecommendedBytes: 128 << 20, CpuRecommendedCores: 0.1,
+				CpuLimitRecommendedCores: tt.cpuLimit, MemLimitRecommendedBytes: tt.memLimit,
+			}
+
+			out := writeHelmPatch(t, []model.RightsizeResult{r}, meta)
+			if !strings.HasSuffix(out, tt.want) {
+				t.Errorf("patch ends with:\n%s\nwant suffix:%s", out, tt.want)
+			}
+			if tt.strategy != "remove" && strings.Contains(out, "null") {
+				t.Errorf("null written without the remove strategy:\n%s", out)
+			}
+		})
+	}
+}
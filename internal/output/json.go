@@ -0,0 +1,75 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// JSONSchemaVersion identifies the layout of the rightsize JSON document.
+// Bump it whenever a field is renamed or removed; adding fields is compatible.
+const JSONSchemaVersion = "upctl.rightsize/v2"
+
+type rightsizeDocument struct {
+	SchemaVersion string                  `json:"schema_version"`
+	Meta          model.RightsizeMeta     `json:"meta"`
+	Results       []model.RightsizeResult `json:"results"` // app containers
+	Sidecars      []model.RightsizeResult `json:"sidecars"`
+	Init          []model.RightsizeResult `json:"init_containers"`
+	Namespaces    []model.NamespaceTotals `json:"namespaces"` // all roles
+
+	// Per-service merge, only for multi-cluster runs
+	Merged []model.MergedResult `json:"merged,omitempty"`
+}
+
+func WriteJSON(
+	w io.Writer,
+	results []model.RightsizeResult,
+	merged []model.MergedResult,
+	meta model.RightsizeMeta,
+) error {
+	totals := namespaceTotals(results)
+	if totals == nil {
+		totals = []model.NamespaceTotals{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	sections := splitRoles(results)
+
+	return enc.Encode(rightsizeDocument{
+		SchemaVersion: JSONSchemaVersion,
+		Meta:          meta,
+		Results:       nonNil(sections[model.RoleApp]),
+		Sidecars:      nonNil(sections[model.RoleSidecar]),
+		Init:          nonNil(sections[model.RoleInit]),
+		Namespaces:    totals,
+		Merged:        merged,
+	})
+}
+
+// nonNil always yields an array so consumers never have to special-case null.
+func nonNil(results []model.RightsizeResult) []model.RightsizeResult {
+	if results == nil {
+		return []model.RightsizeResult{}
+	}
+	return results
+}
+
+// ReadJSON loads a document written by WriteJSON, returning the results of
+// every section (apps, sidecars, init containers) and its meta.
+func ReadJSON(r io.Reader) ([]model.RightsizeResult, model.RightsizeMeta, error) {
+	var doc rightsizeDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, model.RightsizeMeta{}, err
+	}
+	if doc.SchemaVersion != JSONSchemaVersion {
+		return nil, doc.Meta, fmt.Errorf("unsupported schema_version %q (want %s)", doc.SchemaVersion, JSONSchemaVersion)
+	}
+
+	results := append(doc.Results, doc.Sidecars...)
+	return append(results, doc.Init...), doc.Meta, nil
+}
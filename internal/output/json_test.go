@@ -0,0 +1,143 @@
+package output
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// golden compares got with testdata/name, rewriting it under -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s differs from the golden file (run go test -update to accept):\n%s", name, got)
+	}
+}
+
+func testMeta() model.RightsizeMeta {
+	return model.RightsizeMeta{
+		Namespace:        "shop",
+		Cluster:          "c1",
+		Clusters:         []string{"c1"},
+		Window:           "7d",
+		OOMWindow:        "7d",
+		SubqueryStep:     "5m",
+		TargetUtil:       0.7,
+		SafetyFactor:     1.1,
+		MemStat:          "p95",
+		CPUStat:          "p95",
+		Mode:             "ratio",
+		ClusterLabel:     "uw_cluster",
+		IdentityLabels:   []string{"namespace", "container", "uw_cluster"},
+		GroupBy:          "container",
+		SortBy:           []string{"mem_delta:asc"},
+		MemLimitRatio:    1.5,
+		CPULimitStrategy: "keep",
+		Policy:           model.PolicyMeta{Name: "default", Source: "builtin"},
+		LowConfidence:    "keep",
+		SidecarPatterns:  []string{"istio-proxy"},
+	}
+}
+
+func testResults() []model.RightsizeResult {
+	return []model.RightsizeResult{
+		{
+			Namespace: "shop", Cluster: "c1", Container: "api", Role: model.RoleApp,
+			MemUsageRatio: 0.3, CpuUsageRatio: 0.4,
+			MemRequestBytes: 1 << 30, CpuRequestCores: 1,
+			Replicas: 3, ReplicasAvg: 3, ReplicasPeak: 4,
+			MemRecommendedBytes: 512 << 20, CpuRecommendedCores: 0.6,
+			MemDeltaBytes: -512 << 20, CpuDeltaCores: -0.4,
+			MemDeltaTotalBytes: -3 * 512 << 20, CpuDeltaTotalCores: -1.2,
+			Coverage: 1, PodAgeHours: 240, Confidence: model.ConfidenceHigh,
+			MemoryDecision: model.MemReduce, CPUDecision: model.CPUReduce,
+			JVMHeapDecision: model.JVMKeep, JVMNonHeapDecision: model.JVMKeep,
+			MemoryWhy: "p95 usage 30% of request", CPUWhy: "p95 usage 40% of request",
+		},
+		{
+			Namespace: "shop", Cluster: "c1", Container: "istio-proxy", Role: model.RoleSidecar,
+			MemRequestBytes: 128 << 20, CpuRequestCores: 0.1,
+			MemRecommendedBytes: 128 << 20, CpuRecommendedCores: 0.1,
+			MemoryDecision: model.MemKeep, CPUDecision: model.CPUKeep,
+			JVMHeapDecision: model.JVMKeep, JVMNonHeapDecision: model.JVMKeep,
+		},
+		{
+			Namespace: "shop", Cluster: "c1", Container: "migrate", Role: model.RoleInit,
+			MemRequestBytes: 256 << 20, CpuRequestCores: 0.5,
+			MemRecommendedBytes: 256 << 20, CpuRecommendedCores: 0.5,
+			MemoryDecision: model.MemKeep, CPUDecision: model.CPUKeep,
+			JVMHeapDecision: model.JVMKeep, JVMNonHeapDecision: model.JVMKeep,
+		},
+	}
+}
+
+func TestWriteJSONGolden(t *testing.T) {
+	var buf strings.Builder
+	if err := WriteJSON(&buf, testResults(), nil, testMeta()); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "rightsize.json", []byte(buf.String()))
+}
+
+func TestWriteJSONEmptySections(t *testing.T) {
+	var buf strings.Builder
+	if err := WriteJSON(&buf, nil, nil, testMeta()); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"results": []`, `"sidecars": []`, `"init_containers": []`, `"namespaces": []`} {
+		if !strings.Contains(buf.String(), key) {
+			t.Errorf("missing %s in\n%s", key, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `"merged"`) {
+		t.Errorf("single-cluster document has a merged section:\n%s", buf.String())
+	}
+}
+
+func TestReadJSONRoundTrip(t *testing.T) {
+	var buf strings.Builder
+	if err := WriteJSON(&buf, testResults(), nil, testMeta()); err != nil {
+		t.Fatal(err)
+	}
+
+	results, meta, err := ReadJSON(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, testResults()) {
+		t.Errorf("results = %+v\nwant %+v", results, testResults())
+	}
+	if !reflect.DeepEqual(meta, testMeta()) {
+		t.Errorf("meta = %+v\nwant %+v", meta, testMeta())
+	}
+}
+
+func TestReadJSONRejectsOtherSchemas(t *testing.T) {
+	for _, doc := range []string{
+		`{"schema_version": "upctl.rightsize/v1", "results": []}`,
+		`{"results": []}`,
+	} {
+		if _, _, err := ReadJSON(strings.NewReader(doc)); err == nil {
+			t.Errorf("ReadJSON(%s) succeeded, want a schema version error", doc)
+		}
+	}
+}
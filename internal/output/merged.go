@@ -0,0 +1,82 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// RenderMergedTable prints one row per service across clusters, sized for
+// the worst cluster, flagging services whose usage diverges between clusters.
+func RenderMergedTable(merged []model.MergedResult) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle("ACROSS CLUSTERS (sized for the worst cluster)")
+
+	workloads := hasWorkloads(MergedResults(merged))
+
+	header := table.Row{"NAMESPACE"}
+	if workloads {
+		header = append(header, "WORKLOAD")
+	}
+	t.AppendHeader(append(header,
+		"CONTAINER",
+		"CLUSTERS",
+		"MEM SPREAD",
+		"CPU SPREAD",
+		"MEM REC",
+		"CPU REC",
+		"MEM DECISION",
+		"CPU DECISION",
+		"CONF",
+	))
+
+	t.SetStyle(table.Style{
+		Name:    "upctl",
+		Box:     table.StyleBoxRounded,
+		Options: table.Options{DrawBorder: true, SeparateRows: true},
+		Title:   table.TitleOptions{Align: text.AlignLeft},
+	})
+
+	for _, m := range merged {
+		r := m.RightsizeResult
+		row := table.Row{r.Namespace}
+		if workloads {
+			row = append(row, r.WorkloadRef())
+		}
+		t.AppendRow(append(row,
+			r.Container,
+			strings.Join(m.Clusters, ","),
+			spreadString(m.MemUsageSpread, m.Diverges),
+			spreadString(m.CpuUsageSpread, m.Diverges),
+			bytes(r.MemRecommendedBytes),
+			fmt.Sprintf("%.2f", r.CpuRecommendedCores),
+			colorMemory(r.MemoryDecision),
+			colorCPU(r.CPUDecision),
+			colorConfidence(r.Confidence),
+		))
+	}
+
+	t.Render()
+}
+
+func spreadString(spread float64, diverges bool) string {
+	s := fmt.Sprintf("×%.2f", spread)
+	if diverges {
+		return text.FgHiRed.Sprint(s + " ⚠")
+	}
+	return s
+}
+
+// MergedResults returns the per-service rows of a merge, e.g. for the Helm patch.
+func MergedResults(merged []model.MergedResult) []model.RightsizeResult {
+	out := make([]model.RightsizeResult, len(merged))
+	for i, m := range merged {
+		out[i] = m.RightsizeResult
+	}
+	return out
+}
@@ -3,65 +3,260 @@ package output
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
 )
 
-func RenderTable(results []model.RightsizeResult) {
+// RenderTable prints app containers, then sidecars and init containers in
+// their own sections (omitted when empty), and the policy that decided them.
+// With pricing, each section gets a savings column and a total footer.
+func RenderTable(results []model.RightsizeResult, meta model.RightsizeMeta) {
+	sections := splitRoles(results)
+	usage := usageColumns{mem: statTitle(meta.MemStat), cpu: statTitle(meta.CPUStat), extra: meta.ExtraStats}
+	priced := meta.Pricing != nil
+	if len(sections[model.RoleApp]) > 0 || len(results) == 0 {
+		renderSection("", usage, priced, sections[model.RoleApp])
+	}
+	if rs := sections[model.RoleSidecar]; len(rs) > 0 {
+		renderSection("SIDECARS (extra headroom)", usage, priced, rs)
+	}
+	if rs := sections[model.RoleInit]; len(rs) > 0 {
+		// Init containers are not priced
+		renderSection("INIT CONTAINERS (sized to peak)", usageColumns{mem: "PEAK", cpu: "PEAK"}, false, rs)
+	}
+	fmt.Println(text.Faint.Sprint("policy: " + policyString(meta.Policy) + "; low confidence: " + meta.LowConfidence))
+	if priced {
+		fmt.Println(text.Faint.Sprint("pricing: " + pricingString(*meta.Pricing)))
+	}
+	if len(meta.Guardrails) > 0 {
+		fmt.Println(text.Faint.Sprint("guardrails: " + strings.Join(meta.Guardrails, ", ")))
+	}
+	if len(meta.Filters) > 0 {
+		fmt.Println(text.Faint.Sprintf("filters: %s (%d rows hidden)", strings.Join(meta.Filters, ", "), meta.Filtered))
+	}
+}
+
+func policyString(p model.PolicyMeta) string {
+	s := p.Name + " (" + p.Source
+	if p.Digest != "" {
+		s += ", " + p.Digest[:min(len(p.Digest), 19)]
+	}
+	return s + ")"
+}
+
+// usageColumns titles the usage ratio columns: the sizing statistic of each
+// resource, followed by the extra statistics shown next to it.
+type usageColumns struct {
+	mem, cpu string
+	extra    []string
+}
+
+func (u usageColumns) titles(resource, sizing string) []any {
+	titles := []any{resource + " " + sizing}
+	for _, st := range u.extra {
+		titles = append(titles, resource+" "+statTitle(st))
+	}
+	return titles
+}
+
+// statTitle renders a statistic for a column header ("" is the p95 default).
+func statTitle(stat string) string {
+	if stat == "" {
+		return "P95"
+	}
+	return strings.ToUpper(stat)
+}
+
+func pricingString(p model.PricingMeta) string {
+	return p.Source + " (" + p.Capacity + ", " + p.Digest[:min(len(p.Digest), 19)] + ")"
+}
+
+func renderSection(title string, usage usageColumns, priced bool, results []model.RightsizeResult) {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
+	if title != "" {
+		t.SetTitle(title)
+	}
+
+	// Multi-namespace runs are grouped with a subtotal row per namespace
+	groups := groupByNamespace(results)
+	grouped := len(groups) > 1
+
+	// Leading key columns, shown only when they tell rows apart
+	var keys []keyColumn
+	if grouped {
+		keys = append(keys, keyColumn{"NAMESPACE", func(r model.RightsizeResult) string { return r.Namespace }})
+	}
+	if len(clusterNames(results)) > 1 {
+		keys = append(keys, keyColumn{"CLUSTER", func(r model.RightsizeResult) string { return r.Cluster }})
+	}
+	if hasWorkloads(results) {
+		keys = append(keys, keyColumn{"WORKLOAD", model.RightsizeResult.WorkloadRef})
+	}
 
 	// Header
-	t.AppendHeader(table.Row{
-		"CONTAINER",
-		"MEM P95",
-		"CPU P95",
+	header := table.Row{}
+	for _, k := range keys {
+		header = append(header, k.title)
+	}
+	header = append(header, "CONTAINER")
+	header = append(header, usage.titles("MEM", usage.mem)...)
+	header = append(header, usage.titles("CPU", usage.cpu)...)
+	header = append(header,
 		"MEM REQ",
 		"MEM REC",
+		"MEM LIM",
 		"CPU REQ",
 		"CPU REC",
+		"CPU LIM",
 		"CPU DECISION",
 		"MEM DECISION",
+		"CONF",
 		"JVM HEAP",
 		"JVM NON-HEAP",
-	})
+		"REPLICAS",
+		"Σ MEM Δ",
+		"Σ CPU Δ",
+	)
+	if priced {
+		header = append(header, "SAVINGS/MO")
+	}
+	t.AppendHeader(header)
 
 	// Style
 	t.SetStyle(table.Style{
 		Name:    "upctl",
 		Box:     table.StyleBoxRounded,
 		Options: table.Options{DrawBorder: true, SeparateRows: true},
+		Title:   table.TitleOptions{Align: text.AlignLeft},
 	})
 
-	for _, r := range results {
-		t.AppendRow(table.Row{
-			r.Container,
-			fmt.Sprintf("%.2f", r.MemP95Ratio),
-			fmt.Sprintf("%.2f", r.CpuP95Ratio),
-			bytes(r.MemRequestBytes),
-			formatMemChange(
-				r.MemRequestBytes,
-				r.MemRecommendedBytes,
-				string(r.MemoryDecision),
-			),
-			fmt.Sprintf("%.2f", r.CpuRequestCores),
-			formatCPUChange(
-				r.CpuRequestCores,
-				r.CpuRecommendedCores,
-				string(r.CPUDecision),
-			),
-			colorCPU(r.CPUDecision),
-			colorMemory(r.MemoryDecision),
-			colorJVM(r.JVMHeapDecision),
-			colorJVM(r.JVMNonHeapDecision),
-		})
+	var savings float64
+	for _, g := range groups {
+		for _, r := range g.Results {
+			row := table.Row{}
+			for _, k := range keys {
+				row = append(row, k.value(r))
+			}
+			row = append(row, resultRow(r, usage.extra)...)
+			if priced {
+				row = append(row, formatSavings(r.EstSavingsPerMonthUSD))
+			}
+			t.AppendRow(row)
+		}
 
+		if grouped {
+			row := subtotalRow(g.Totals, len(keys)-1, 2*(1+len(usage.extra)))
+			if priced {
+				row = append(row, formatSavings(g.Totals.EstSavingsPerMonthUSD))
+			}
+			t.AppendRow(row)
+		}
+		savings += g.Totals.EstSavingsPerMonthUSD
+	}
+
+	if priced {
+		footer := table.Row{"TOTAL"}
+		for len(footer) < len(header)-1 {
+			footer = append(footer, "")
+		}
+		t.AppendFooter(append(footer, formatSavings(savings)))
 	}
 
 	t.Render()
 }
 
+type keyColumn struct {
+	title string
+	value func(model.RightsizeResult) string
+}
+
+func hasWorkloads(results []model.RightsizeResult) bool {
+	for _, r := range results {
+		if r.Workload != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func resultRow(r model.RightsizeResult, extra []string) table.Row {
+	row := table.Row{r.Container}
+	row = append(row, statCells(r.MemUsageRatio, r.MemUsageStats, extra)...)
+	row = append(row, statCells(r.CpuUsageRatio, r.CpuUsageStats, extra)...)
+	return append(row,
+		bytes(r.MemRequestBytes),
+		formatMemChange(
+			r.MemRequestBytes,
+			r.MemRecommendedBytes,
+			string(r.MemoryDecision),
+		),
+		formatMemLimit(r.MemLimitBytes, r.MemLimitRecommendedBytes),
+		fmt.Sprintf("%.2f", r.CpuRequestCores),
+		formatCPUChange(
+			r.CpuRequestCores,
+			r.CpuRecommendedCores,
+			string(r.CPUDecision),
+		),
+		formatCPULimit(r.CpuLimitCores, r.CpuLimitRecommendedCores),
+		colorCPU(r.CPUDecision),
+		colorMemory(r.MemoryDecision),
+		colorConfidence(r.Confidence),
+		colorJVM(r.JVMHeapDecision),
+		colorJVM(r.JVMNonHeapDecision),
+		formatReplicas(r.ReplicasAvg, r.ReplicasPeak),
+		formatMemDelta(r.MemDeltaTotalBytes),
+		formatCPUDelta(r.CpuDeltaTotalCores),
+	)
+}
+
+// statCells renders the sizing ratio and then each extra statistic ("-"
+// when it was not fetched, e.g. for init containers).
+func statCells(sizing float64, stats map[string]float64, extra []string) []any {
+	cells := []any{fmt.Sprintf("%.2f", sizing)}
+	for _, st := range extra {
+		v, ok := stats[st]
+		if !ok {
+			cells = append(cells, "-")
+			continue
+		}
+		cells = append(cells, fmt.Sprintf("%.2f", v))
+	}
+	return cells
+}
+
+// subtotalRow puts the namespace in the first key column and leaves the
+// other `blank` key columns and the `ratios` usage ratio columns empty.
+func subtotalRow(tot model.NamespaceTotals, blank, ratios int) table.Row {
+	row := table.Row{text.Bold.Sprint(tot.Namespace)}
+	for range blank {
+		row = append(row, "")
+	}
+	row = append(row, text.Bold.Sprintf("Σ %d containers", tot.Containers))
+	for range ratios {
+		row = append(row, "")
+	}
+	return append(row,
+		bytes(tot.MemRequestBytes),
+		formatMemChange(tot.MemRequestBytes, tot.MemRecommendedBytes, ""),
+		"",
+		fmt.Sprintf("%.2f", tot.CpuRequestCores),
+		formatCPUChange(tot.CpuRequestCores, tot.CpuRecommendedCores, ""),
+		"",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"",
+		formatMemDelta(tot.MemDeltaTotalBytes),
+		formatCPUDelta(tot.CpuDeltaTotalCores),
+	)
+}
+
 func bytes(b int64) string {
 	const (
 		KiB = 1024
@@ -0,0 +1,67 @@
+package output
+
+import (
+	"os"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// RuleChange is one result before and after policy rules were applied.
+type RuleChange struct {
+	Before, After model.RightsizeResult
+}
+
+// RenderRuleChanges prints the decisions policy rules changed.
+func RenderRuleChanges(changes []RuleChange) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+
+	t.AppendHeader(table.Row{
+		"NAMESPACE",
+		"WORKLOAD",
+		"CONTAINER",
+		"RULES",
+		"CPU",
+		"MEMORY",
+		"WHY",
+	})
+
+	t.SetStyle(table.Style{
+		Name:    "upctl",
+		Box:     table.StyleBoxRounded,
+		Options: table.Options{DrawBorder: true, SeparateRows: true},
+	})
+
+	for _, c := range changes {
+		a := c.After
+		var why []string
+		if a.CPUDecision != c.Before.CPUDecision || a.CPUWhy != c.Before.CPUWhy {
+			why = append(why, "cpu: "+a.CPUWhy)
+		}
+		if a.MemoryDecision != c.Before.MemoryDecision || a.MemoryWhy != c.Before.MemoryWhy {
+			why = append(why, "memory: "+a.MemoryWhy)
+		}
+
+		t.AppendRow(table.Row{
+			a.Namespace,
+			a.WorkloadRef(),
+			a.Container,
+			strings.Join(a.PolicyRules, ","),
+			decisionChange(string(c.Before.CPUDecision), string(a.CPUDecision)),
+			decisionChange(string(c.Before.MemoryDecision), string(a.MemoryDecision)),
+			strings.Join(why, "\n"),
+		})
+	}
+
+	t.Render()
+}
+
+func decisionChange(before, after string) string {
+	if before == after {
+		return text.Faint.Sprint(after)
+	}
+	return colorDecision(before) + " → " + colorDecision(after)
+}
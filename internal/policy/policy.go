@@ -0,0 +1,279 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/service/decision"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the YAML policy document:
+//
+//	name: prod
+//	defaults:
+//	  cpu:    {reduce-below: 0.60, increase-above: 0.90}
+//	  memory: {reduce-below: 0.60, increase-above: 0.90}
+//	  jvm:    {heap-after-gc-above: 0.80, non-heap-ratio-above: 0.30}
+//	  confidence: {min-coverage: 0.50, high-coverage: 0.90, min-pod-age-hours: 24, max-restarts: 5}
+//	overrides:
+//	  - name: batch
+//	    match: {namespace: "batch-.*"}
+//	    cpu: {reduce-below: 0.40}
+//
+// Unset defaults fall back to the built-in bands. Every matching override
+// applies in file order, so later ones win.
+type File struct {
+	Name      string     `yaml:"name"`
+	Defaults  Rules      `yaml:"defaults"`
+	Overrides []Override `yaml:"overrides"`
+	Rules     []Rule     `yaml:"rules"` // see Rule
+}
+
+type Rules struct {
+	CPU    *Band `yaml:"cpu"`
+	Memory *Band `yaml:"memory"`
+	JVM    *JVM  `yaml:"jvm"`
+
+	Confidence *Confidence `yaml:"confidence"`
+}
+
+type Band struct {
+	ReduceBelow   *float64 `yaml:"reduce-below"`
+	IncreaseAbove *float64 `yaml:"increase-above"`
+}
+
+type JVM struct {
+	HeapAfterGCAbove  *float64 `yaml:"heap-after-gc-above"`
+	NonHeapRatioAbove *float64 `yaml:"non-heap-ratio-above"`
+}
+
+type Confidence struct {
+	MinCoverage    *float64 `yaml:"min-coverage"`
+	HighCoverage   *float64 `yaml:"high-coverage"`
+	MinPodAgeHours *float64 `yaml:"min-pod-age-hours"`
+	MaxRestarts    *float64 `yaml:"max-restarts"`
+}
+
+type Override struct {
+	Name  string `yaml:"name"`
+	Match Match  `yaml:"match"`
+	Rules `yaml:",inline"`
+}
+
+// Match selects containers by RE2 patterns (full match); empty matches all.
+type Match struct {
+	Namespace string `yaml:"namespace"`
+	Workload  string `yaml:"workload"`
+	Container string `yaml:"container"`
+}
+
+// Policy is a validated policy, ready to resolve thresholds per container.
+type Policy struct {
+	Meta model.PolicyMeta
+
+	defaults  decision.Thresholds
+	overrides []override
+	rules     []rule
+}
+
+type override struct {
+	name                           string
+	namespace, workload, container *regexp.Regexp
+	rules                          Rules
+}
+
+// Default is the built-in policy.
+func Default() *Policy {
+	return &Policy{
+		Meta:     model.PolicyMeta{Name: "default", Source: "builtin"},
+		defaults: decision.DefaultThresholds,
+	}
+}
+
+// Load reads and validates a policy file.
+func Load(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+	p.Meta.Source = path
+	return p, nil
+}
+
+// Parse validates a policy document. Unknown keys are rejected so typos
+// never silently fall back to defaults.
+func Parse(raw []byte) (*Policy, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	p := &Policy{
+		Meta: model.PolicyMeta{
+			Name:   f.Name,
+			Digest: "sha256:" + hex.EncodeToString(sum[:]),
+		},
+		defaults: f.Defaults.apply(decision.DefaultThresholds),
+	}
+	if p.Meta.Name == "" {
+		p.Meta.Name = "unnamed"
+	}
+	if err := validate(p.defaults); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+
+	for i, o := range f.Overrides {
+		name := o.Name
+		if name == "" {
+			name = fmt.Sprintf("overrides[%d]", i)
+		}
+		if o.Match == (Match{}) {
+			return nil, fmt.Errorf("%s: match needs a namespace, workload or container pattern", name)
+		}
+
+		c := override{name: name, rules: o.Rules}
+		var err error
+		if c.namespace, err = compile(o.Match.Namespace); err != nil {
+			return nil, fmt.Errorf("%s: namespace: %w", name, err)
+		}
+		if c.workload, err = compile(o.Match.Workload); err != nil {
+			return nil, fmt.Errorf("%s: workload: %w", name, err)
+		}
+		if c.container, err = compile(o.Match.Container); err != nil {
+			return nil, fmt.Errorf("%s: container: %w", name, err)
+		}
+		// Each override must be valid on top of the defaults by itself
+		if err := validate(o.Rules.apply(p.defaults)); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		p.overrides = append(p.overrides, c)
+	}
+
+	for i, r := range f.Rules {
+		c, err := compileRule(i, r)
+		if err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, c)
+	}
+	p.Meta.Rules = len(p.rules)
+
+	return p, nil
+}
+
+// For resolves the thresholds of one container and the names of the
+// overrides that shaped them.
+func (p *Policy) For(namespace, workload, container string) (decision.Thresholds, []string) {
+	t := p.defaults
+	var applied []string
+	for _, o := range p.overrides {
+		if o.matches(namespace, workload, container) {
+			t = o.rules.apply(t)
+			applied = append(applied, o.name)
+		}
+	}
+	return t, applied
+}
+
+func (o override) matches(namespace, workload, container string) bool {
+	return matchOrAny(o.namespace, namespace) &&
+		matchOrAny(o.workload, workload) &&
+		matchOrAny(o.container, container)
+}
+
+func matchOrAny(re *regexp.Regexp, s string) bool {
+	return re == nil || re.MatchString(s)
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// apply overlays the set fields of r onto t.
+func (r Rules) apply(t decision.Thresholds) decision.Thresholds {
+	if r.CPU != nil {
+		t.CPU = r.CPU.apply(t.CPU)
+	}
+	if r.Memory != nil {
+		t.Memory = r.Memory.apply(t.Memory)
+	}
+	if r.JVM != nil {
+		set(&t.JVMHeapAfterGC, r.JVM.HeapAfterGCAbove)
+		set(&t.JVMNonHeapRatio, r.JVM.NonHeapRatioAbove)
+	}
+	if c := r.Confidence; c != nil {
+		set(&t.Confidence.MinCoverage, c.MinCoverage)
+		set(&t.Confidence.HighCoverage, c.HighCoverage)
+		set(&t.Confidence.MinPodAgeHours, c.MinPodAgeHours)
+		set(&t.Confidence.MaxRestarts, c.MaxRestarts)
+	}
+	return t
+}
+
+func (b Band) apply(d decision.Band) decision.Band {
+	set(&d.Reduce, b.ReduceBelow)
+	set(&d.Increase, b.IncreaseAbove)
+	return d
+}
+
+func set(dst *float64, v *float64) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func validate(t decision.Thresholds) error {
+	if err := validateBand("cpu", t.CPU); err != nil {
+		return err
+	}
+	if err := validateBand("memory", t.Memory); err != nil {
+		return err
+	}
+	if t.JVMHeapAfterGC <= 0 || t.JVMHeapAfterGC > 1 {
+		return fmt.Errorf("jvm.heap-after-gc-above %.2f must be in (0, 1]", t.JVMHeapAfterGC)
+	}
+	if t.JVMNonHeapRatio <= 0 || t.JVMNonHeapRatio > 1 {
+		return fmt.Errorf("jvm.non-heap-ratio-above %.2f must be in (0, 1]", t.JVMNonHeapRatio)
+	}
+	if c := t.Confidence; c.MinCoverage < 0 || c.HighCoverage < c.MinCoverage || c.HighCoverage > 1 {
+		return fmt.Errorf("confidence: want 0 <= min-coverage %.2f <= high-coverage %.2f <= 1", c.MinCoverage, c.HighCoverage)
+	}
+	if t.Confidence.MinPodAgeHours < 0 {
+		return fmt.Errorf("confidence.min-pod-age-hours %.1f must not be negative", t.Confidence.MinPodAgeHours)
+	}
+	if t.Confidence.MaxRestarts < 0 {
+		return fmt.Errorf("confidence.max-restarts %.0f must not be negative", t.Confidence.MaxRestarts)
+	}
+	return nil
+}
+
+func validateBand(name string, b decision.Band) error {
+	if b.Reduce < 0 {
+		return fmt.Errorf("%s.reduce-below %.2f must not be negative", name, b.Reduce)
+	}
+	if b.Increase <= b.Reduce {
+		return fmt.Errorf("%s.increase-above %.2f must be greater than reduce-below %.2f", name, b.Increase, b.Reduce)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/service/decision"
+)
+
+const testPolicy = `
+name: prod
+defaults:
+  cpu: {reduce-below: 0.50}
+  confidence: {min-pod-age-hours: 48}
+overrides:
+  - name: batch
+    match: {namespace: "batch-.*"}
+    cpu: {reduce-below: 0.30, increase-above: 0.95}
+  - name: batch-workers
+    match: {namespace: "batch-.*", container: "worker"}
+    cpu: {reduce-below: 0.20}
+    memory: {increase-above: 0.80}
+`
+
+func TestParseDefaults(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Meta.Name != "prod" || !strings.HasPrefix(p.Meta.Digest, "sha256:") {
+		t.Errorf("meta = %+v", p.Meta)
+	}
+
+	th, overrides := p.For("shop", "api", "api")
+	if len(overrides) != 0 {
+		t.Errorf("overrides = %v, want none", overrides)
+	}
+	want := decision.DefaultThresholds
+	want.CPU.Reduce = 0.50
+	want.Confidence.MinPodAgeHours = 48
+	if th != want {
+		t.Errorf("thresholds = %+v\nwant %+v", th, want)
+	}
+}
+
+func TestForAppliesOverridesInOrder(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		namespace, container string
+		overrides            []string
+		cpu, memory          decision.Band
+	}{
+		{
+			namespace: "batch-nightly", container: "api",
+			overrides: []string{"batch"},
+			cpu:       decision.Band{Reduce: 0.30, Increase: 0.95},
+			memory:    decision.DefaultThresholds.Memory,
+		},
+		{
+			// Both match; the later override wins where they overlap
+			namespace: "batch-nightly", container: "worker",
+			overrides: []string{"batch", "batch-workers"},
+			cpu:       decision.Band{Reduce: 0.20, Increase: 0.95},
+			memory:    decision.Band{Reduce: 0.60, Increase: 0.80},
+		},
+		{
+			// Patterns match the whole name
+			namespace: "prod-batch-nightly", container: "worker",
+			cpu:    decision.Band{Reduce: 0.50, Increase: 0.90},
+			memory: decision.DefaultThresholds.Memory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.namespace+"/"+tt.container, func(t *testing.T) {
+			th, overrides := p.For(tt.namespace, "", tt.container)
+			if !slices.Equal(overrides, tt.overrides) {
+				t.Errorf("overrides = %v, want %v", overrides, tt.overrides)
+			}
+			if th.CPU != tt.cpu || th.Memory != tt.memory {
+				t.Errorf("cpu %+v memory %+v, want cpu %+v memory %+v", th.CPU, th.Memory, tt.cpu, tt.memory)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidPolicies(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":            "defaults:\n  cpu: {reduce-bellow: 0.5}\n",
+		"inverted band":          "defaults:\n  memory: {reduce-below: 0.9, increase-above: 0.5}\n",
+		"negative reduce":        "defaults:\n  cpu: {reduce-below: -0.1}\n",
+		"jvm ratio above 1":      "defaults:\n  jvm: {heap-after-gc-above: 1.5}\n",
+		"coverage bars":          "defaults:\n  confidence: {min-coverage: 0.9, high-coverage: 0.5}\n",
+		"override without match": "overrides:\n  - name: x\n    cpu: {reduce-below: 0.1}\n",
+		"override bad regexp":    "overrides:\n  - match: {container: \"(\"}\n",
+		"override inverts band":  "overrides:\n  - match: {container: api}\n    cpu: {increase-above: 0.5}\n",
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(doc)); err == nil {
+				t.Errorf("Parse succeeded, want an error for:\n%s", doc)
+			}
+		})
+	}
+}
+
+func TestParseEmptyIsDefault(t *testing.T) {
+	p, err := Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th, _ := p.For("any", "", "any"); th != decision.DefaultThresholds {
+		t.Errorf("thresholds = %+v, want the built-in defaults", th)
+	}
+	if p.Meta.Name != "unnamed" {
+		t.Errorf("name = %q, want unnamed", p.Meta.Name)
+	}
+}
@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Rule is a custom decision evaluated after the built-in ones:
+//
+//	rules:
+//	  - name: payments-cpu-floor
+//	    when: Namespace == "payments" && CPUDecision == "REDUCE" && CpuRecommendedCores < 0.25
+//	    cpu: KEEP
+//	    why: never reduce CPU below 250m in payments
+//
+// `when` is a boolean expression over model.RightsizeResult fields (Go
+// names). `decision` sets both resources; `cpu`/`memory` set one. Rules
+// run in file order and the first rule to decide a resource wins.
+// KEEP and SKIP reset that resource's recommendation to its request.
+type Rule struct {
+	Name     string `yaml:"name"`
+	When     string `yaml:"when"`
+	Decision string `yaml:"decision"`
+	CPU      string `yaml:"cpu"`
+	Memory   string `yaml:"memory"`
+	Why      string `yaml:"why"`
+}
+
+type rule struct {
+	name   string
+	when   *vm.Program
+	cpu    model.CPUDecision    // "" = not decided by this rule
+	memory model.MemoryDecision // "" = not decided by this rule
+	why    string
+}
+
+var (
+	cpuDecisions = map[string]model.CPUDecision{
+		"REDUCE":   model.CPUReduce,
+		"KEEP":     model.CPUKeep,
+		"INCREASE": model.CPUIncrease,
+		"SKIP":     model.CPUSkip,
+	}
+	memDecisions = map[string]model.MemoryDecision{
+		"REDUCE":   model.MemReduce,
+		"KEEP":     model.MemKeep,
+		"INCREASE": model.MemIncrease,
+		"SKIP":     model.MemSkip,
+	}
+)
+
+func compileRule(i int, r Rule) (rule, error) {
+	c := rule{name: r.Name, why: r.Why}
+	if c.name == "" {
+		c.name = fmt.Sprintf("rules[%d]", i)
+	}
+	if r.When == "" {
+		return c, fmt.Errorf("%s: when is required", c.name)
+	}
+
+	prog, err := expr.Compile(r.When, expr.Env(ruleEnv(model.RightsizeResult{})), expr.AsBool())
+	if err != nil {
+		return c, fmt.Errorf("%s: when: %w", c.name, err)
+	}
+	c.when = prog
+
+	cpu, mem := r.CPU, r.Memory
+	if cpu == "" {
+		cpu = r.Decision
+	}
+	if mem == "" {
+		mem = r.Decision
+	}
+	if cpu != "" {
+		var ok bool
+		if c.cpu, ok = cpuDecisions[cpu]; !ok {
+			return c, fmt.Errorf("%s: unknown cpu decision %q (want REDUCE|KEEP|INCREASE|SKIP)", c.name, cpu)
+		}
+	}
+	if mem != "" {
+		var ok bool
+		if c.memory, ok = memDecisions[mem]; !ok {
+			return c, fmt.Errorf("%s: unknown memory decision %q (want REDUCE|KEEP|INCREASE|SKIP)", c.name, mem)
+		}
+	}
+	if c.cpu == "" && c.memory == "" {
+		return c, fmt.Errorf("%s: needs a decision, cpu or memory", c.name)
+	}
+	if c.why == "" {
+		c.why = "matched " + r.When
+	}
+	return c, nil
+}
+
+// Apply evaluates the rules against a result that already carries the
+// built-in decisions, and rewrites the decisions they match.
+func (p *Policy) Apply(r *model.RightsizeResult) error {
+	env := ruleEnv(*r) // rules see the built-in decisions, not each other's
+	var cpuDone, memDone bool
+
+	for _, rl := range p.rules {
+		if (rl.cpu == "" || cpuDone) && (rl.memory == "" || memDone) {
+			continue
+		}
+		out, err := expr.Run(rl.when, env)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rl.name, err)
+		}
+		if !out.(bool) {
+			continue
+		}
+
+		why := "rule " + rl.name + ": " + rl.why
+		applied := false
+		if rl.cpu != "" && !cpuDone {
+			r.CPUDecision, r.CPUWhy = rl.cpu, why
+			if rl.cpu == model.CPUKeep || rl.cpu == model.CPUSkip {
+				r.CpuRecommendedCores, r.CpuDeltaCores = r.CpuRequestCores, 0
+			}
+			cpuDone, applied = true, true
+		}
+		if rl.memory != "" && !memDone {
+			r.MemoryDecision, r.MemoryWhy = rl.memory, why
+			if rl.memory == model.MemKeep || rl.memory == model.MemSkip {
+				r.MemRecommendedBytes, r.MemDeltaBytes = r.MemRequestBytes, 0
+			}
+			memDone, applied = true, true
+		}
+		if applied {
+			r.PolicyRules = append(r.PolicyRules, rl.name)
+		}
+	}
+	return nil
+}
+
+// ruleEnv exposes the result's fields by Go name. Decision types become plain
+// strings so rules can compare them with literals (CPUDecision == "REDUCE").
+func ruleEnv(r model.RightsizeResult) map[string]any {
+	v := reflect.ValueOf(r)
+	env := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if fv := v.Field(i); fv.Kind() == reflect.String {
+			env[f.Name] = fv.String()
+		} else {
+			env[f.Name] = fv.Interface()
+		}
+	}
+	return env
+}
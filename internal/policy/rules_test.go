@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// reducing is a result whose built-in decisions reduce both resources.
+func reducing() model.RightsizeResult {
+	return model.RightsizeResult{
+		Namespace: "payments", Container: "api",
+		MemRequestBytes: 1 << 30, MemRecommendedBytes: 512 << 20, MemDeltaBytes: -512 << 20,
+		CpuRequestCores: 1, CpuRecommendedCores: 0.2, CpuDeltaCores: -0.8,
+		MemoryDecision: model.MemReduce, MemoryWhy: "built-in",
+		CPUDecision: model.CPUReduce, CPUWhy: "built-in",
+	}
+}
+
+func TestApplyRulePrecedence(t *testing.T) {
+	p, err := Parse([]byte(`
+rules:
+  - name: cpu-floor
+    when: Namespace == "payments" && CPUDecision == "REDUCE" && CpuRecommendedCores < 0.25
+    cpu: KEEP
+  - name: never-cpu
+    when: Namespace == "payments"
+    decision: SKIP
+  - name: sees-built-in
+    when: CPUDecision == "REDUCE"
+    memory: INCREASE
+  - name: too-late
+    when: "true"
+    memory: KEEP
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := reducing()
+	if err := p.Apply(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	// cpu-floor decides CPU first; never-cpu then only has memory left,
+	// so it decides it and the later memory rules do nothing
+	if r.CPUDecision != model.CPUKeep || r.CPUWhy != `rule cpu-floor: matched Namespace == "payments" && CPUDecision == "REDUCE" && CpuRecommendedCores < 0.25` {
+		t.Errorf("cpu = %s (%q), want KEEP from cpu-floor", r.CPUDecision, r.CPUWhy)
+	}
+	if r.MemoryDecision != model.MemSkip {
+		t.Errorf("memory = %s (%q), want SKIP from never-cpu", r.MemoryDecision, r.MemoryWhy)
+	}
+	if want := []string{"cpu-floor", "never-cpu"}; !slices.Equal(r.PolicyRules, want) {
+		t.Errorf("rules = %v, want %v", r.PolicyRules, want)
+	}
+}
+
+func TestApplyRulesSeeBuiltInDecisions(t *testing.T) {
+	p, err := Parse([]byte(`
+rules:
+  - name: keep-cpu
+    when: "true"
+    cpu: KEEP
+  - name: follow-cpu
+    when: CPUDecision == "REDUCE"
+    memory: INCREASE
+    why: cpu was reducing
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := reducing()
+	if err := p.Apply(&r); err != nil {
+		t.Fatal(err)
+	}
+	// follow-cpu sees the built-in REDUCE, not keep-cpu's KEEP
+	if r.MemoryDecision != model.MemIncrease || r.MemoryWhy != "rule follow-cpu: cpu was reducing" {
+		t.Errorf("memory = %s (%q), want INCREASE from follow-cpu", r.MemoryDecision, r.MemoryWhy)
+	}
+	// INCREASE keeps the recommendation; only KEEP and SKIP reset it
+	if r.MemRecommendedBytes != 512<<20 {
+		t.Errorf("memory recommendation = %d, want it untouched", r.MemRecommendedBytes)
+	}
+}
+
+func TestApplyKeepAndSkipResetRecommendation(t *testing.T) {
+	for _, d := range []string{"KEEP", "SKIP"} {
+		t.Run(d, func(t *testing.T) {
+			p, err := Parse([]byte("rules:\n  - when: \"true\"\n    decision: " + d + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := reducing()
+			if err := p.Apply(&r); err != nil {
+				t.Fatal(err)
+			}
+			if r.MemRecommendedBytes != r.MemRequestBytes || r.MemDeltaBytes != 0 {
+				t.Errorf("memory = %d (delta %d), want the request", r.MemRecommendedBytes, r.MemDeltaBytes)
+			}
+			if r.CpuRecommendedCores != r.CpuRequestCores || r.CpuDeltaCores != 0 {
+				t.Errorf("cpu = %v (delta %v), want the request", r.CpuRecommendedCores, r.CpuDeltaCores)
+			}
+			if !slices.Equal(r.PolicyRules, []string{"rules[0]"}) {
+				t.Errorf("rules = %v, want [rules[0]]", r.PolicyRules)
+			}
+		})
+	}
+}
+
+func TestApplyNoMatch(t *testing.T) {
+	p, err := Parse([]byte("rules:\n  - when: Namespace == \"other\"\n    decision: KEEP\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := reducing()
+	if err := p.Apply(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.MemoryDecision != model.MemReduce || r.CPUDecision != model.CPUReduce || r.PolicyRules != nil {
+		t.Errorf("unmatched rule changed the result: %+v", r)
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	tests := map[string]string{
+		"no when":          "rules:\n  - decision: KEEP\n",
+		"no decision":      "rules:\n  - when: \"true\"\n",
+		"unknown decision": "rules:\n  - when: \"true\"\n    cpu: DROP\n",
+		"unknown field":    "rules:\n  - when: Nmespace == \"x\"\n    decision: KEEP\n",
+		"not boolean":      "rules:\n  - when: CpuRequestCores\n    decision: KEEP\n",
+		"syntax error":     "rules:\n  - when: Namespace ==\n    decision: KEEP\n",
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(doc)); err == nil {
+				t.Errorf("Parse succeeded, want an error for:\n%s", doc)
+			}
+		})
+	}
+}
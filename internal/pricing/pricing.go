@@ -0,0 +1,270 @@
+package pricing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/policy"
+	"gopkg.in/yaml.v3"
+)
+
+// Capacity types a workload can be billed at.
+const (
+	OnDemand = "on-demand"
+	Spot     = "spot"
+)
+
+// HoursPerMonth is the average month (365d * 24h / 12) used for monthly figures.
+const HoursPerMonth = 730
+
+// File is the YAML pricing document (USD):
+//
+//	capacity: on-demand
+//	rates:
+//	  on-demand: {vcpu-hour: 0.0316, gib-hour: 0.0042}
+//	  spot:      {vcpu-hour: 0.0095, gib-hour: 0.0013}
+//	clusters:
+//	  prod-eu-1:
+//	    rates:
+//	      on-demand: {vcpu-hour: 0.0350, gib-hour: 0.0047}
+//	node-pools:
+//	  - name: batch-spot
+//	    cluster: "prod-.*"
+//	    match: {namespace: "batch-.*"}
+//	    capacity: spot
+//
+// Clusters override the default rates and capacity; the first matching
+// node pool overrides its cluster's.
+type File struct {
+	Capacity  string             `yaml:"capacity"` // on-demand|spot (default on-demand)
+	Rates     Tiers              `yaml:"rates"`
+	Clusters  map[string]Cluster `yaml:"clusters"`
+	NodePools []NodePool         `yaml:"node-pools"`
+}
+
+// Tiers holds the rates of each capacity type; unset tiers are inherited.
+type Tiers struct {
+	OnDemand *Rate `yaml:"on-demand"`
+	Spot     *Rate `yaml:"spot"`
+}
+
+type Rate struct {
+	VCPUHour float64 `yaml:"vcpu-hour"`
+	GiBHour  float64 `yaml:"gib-hour"`
+}
+
+type Cluster struct {
+	Capacity string `yaml:"capacity"`
+	Rates    Tiers  `yaml:"rates"`
+}
+
+// NodePool prices the containers it matches; Cluster is an RE2 pattern
+// (full match, empty matches all) and Match selects containers like a
+// policy override.
+type NodePool struct {
+	Name     string       `yaml:"name"`
+	Cluster  string       `yaml:"cluster"`
+	Match    policy.Match `yaml:"match"`
+	Capacity string       `yaml:"capacity"`
+	Rates    Tiers        `yaml:"rates"`
+}
+
+// Pricing is a validated pricing model, ready to price containers.
+type Pricing struct {
+	Meta model.PricingMeta
+
+	base     level
+	clusters map[string]level
+	pools    []pool
+}
+
+// level is a capacity type and rates, layered default < cluster < node pool.
+type level struct {
+	capacity string
+	rates    Tiers
+}
+
+type pool struct {
+	name                                    string
+	cluster, namespace, workload, container *regexp.Regexp
+	level                                   level
+}
+
+// Load reads and validates a pricing file.
+func Load(path string) (*Pricing, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("pricing %s: %w", path, err)
+	}
+	p.Meta.Source = path
+	return p, nil
+}
+
+// Parse validates a pricing document. Every capacity type in use must
+// resolve to rates in every cluster, so pricing never fails mid-run.
+func Parse(raw []byte) (*Pricing, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	p := &Pricing{
+		Meta:     model.PricingMeta{Digest: "sha256:" + hex.EncodeToString(sum[:])},
+		base:     level{capacity: OnDemand}.over(level{f.Capacity, f.Rates}),
+		clusters: map[string]level{},
+	}
+	p.Meta.Capacity = p.base.capacity
+	if err := p.base.validate(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(f.Clusters))
+	for name := range f.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := f.Clusters[name]
+		l := p.base.over(level{c.Capacity, c.Rates})
+		if err := l.validate(); err != nil {
+			return nil, fmt.Errorf("clusters.%s: %w", name, err)
+		}
+		p.clusters[name] = l
+	}
+
+	for i, np := range f.NodePools {
+		name := np.Name
+		if name == "" {
+			name = fmt.Sprintf("node-pools[%d]", i)
+		}
+		if np.Cluster == "" && np.Match == (policy.Match{}) {
+			return nil, fmt.Errorf("%s: needs a cluster or match pattern", name)
+		}
+
+		c := pool{name: name, level: level{np.Capacity, np.Rates}}
+		for _, m := range []struct {
+			field   string
+			re      **regexp.Regexp
+			pattern string
+		}{
+			{"cluster", &c.cluster, np.Cluster},
+			{"namespace", &c.namespace, np.Match.Namespace},
+			{"workload", &c.workload, np.Match.Workload},
+			{"container", &c.container, np.Match.Container},
+		} {
+			re, err := compile(m.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", name, m.field, err)
+			}
+			*m.re = re
+		}
+
+		// The pool must price containers on top of every level it can land on
+		if err := p.base.over(c.level).validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, cl := range names {
+			if matchOrAny(c.cluster, cl) {
+				if err := p.clusters[cl].over(c.level).validate(); err != nil {
+					return nil, fmt.Errorf("%s (cluster %s): %w", name, cl, err)
+				}
+			}
+		}
+		p.pools = append(p.pools, c)
+	}
+
+	return p, nil
+}
+
+// For resolves the rate of one container, and the tier it is billed at
+// (e.g. "spot", or "batch-spot/spot" for a node pool).
+func (p *Pricing) For(cluster, namespace, workload, container string) (Rate, string) {
+	l, ok := p.clusters[cluster]
+	if !ok {
+		l = p.base
+	}
+	tier := ""
+	for _, np := range p.pools {
+		if matchOrAny(np.cluster, cluster) &&
+			matchOrAny(np.namespace, namespace) &&
+			matchOrAny(np.workload, workload) &&
+			matchOrAny(np.container, container) {
+			l = l.over(np.level)
+			tier = np.name + "/"
+			break
+		}
+	}
+	return *l.rate(), tier + l.capacity
+}
+
+// Savings is the hourly cost released by a change of requests across all
+// replicas (negative: added cost).
+func (r Rate) Savings(cpuDeltaCores float64, memDeltaBytes int64, replicas float64) float64 {
+	const GiB = 1024 * 1024 * 1024
+	perPod := cpuDeltaCores*r.VCPUHour + float64(memDeltaBytes)/GiB*r.GiBHour
+	return -perPod * replicas
+}
+
+// over layers o on top of l: set fields of o win.
+func (l level) over(o level) level {
+	if o.capacity != "" {
+		l.capacity = o.capacity
+	}
+	if o.rates.OnDemand != nil {
+		l.rates.OnDemand = o.rates.OnDemand
+	}
+	if o.rates.Spot != nil {
+		l.rates.Spot = o.rates.Spot
+	}
+	return l
+}
+
+func (l level) rate() *Rate {
+	if l.capacity == Spot {
+		return l.rates.Spot
+	}
+	return l.rates.OnDemand
+}
+
+func (l level) validate() error {
+	if l.capacity != OnDemand && l.capacity != Spot {
+		return fmt.Errorf("unknown capacity %q (want %s|%s)", l.capacity, OnDemand, Spot)
+	}
+	r := l.rate()
+	if r == nil {
+		return fmt.Errorf("no %s rates", l.capacity)
+	}
+	if r.VCPUHour < 0 || r.GiBHour < 0 {
+		return fmt.Errorf("%s rates must not be negative", l.capacity)
+	}
+	return nil
+}
+
+func matchOrAny(re *regexp.Regexp, s string) bool {
+	return re == nil || re.MatchString(s)
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
@@ -0,0 +1,109 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+const gib = 1 << 30
+
+func TestSavings(t *testing.T) {
+	r := Rate{VCPUHour: 0.04, GiBHour: 0.005}
+
+	tests := []struct {
+		name     string
+		cpu      float64
+		mem      int64
+		replicas float64
+		want     float64
+	}{
+		{name: "reduction saves", cpu: -1, mem: -2 * gib, replicas: 1, want: 0.05},
+		{name: "increase costs", cpu: 0.5, mem: gib, replicas: 1, want: -0.025},
+		{name: "scaled by replicas", cpu: -1, mem: -2 * gib, replicas: 3, want: 0.15},
+		{name: "fractional replicas", cpu: -1, mem: 0, replicas: 2.5, want: 0.1},
+		{name: "mixed directions", cpu: -1, mem: 4 * gib, replicas: 2, want: 0.04},
+		{name: "no replicas", cpu: -1, mem: -gib, replicas: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Savings(tt.cpu, tt.mem, tt.replicas)
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Savings(%v, %d, %v) = %v, want %v", tt.cpu, tt.mem, tt.replicas, got, tt.want)
+			}
+		})
+	}
+}
+
+const testPricing = `
+rates:
+  on-demand: {vcpu-hour: 0.04, gib-hour: 0.005}
+  spot:      {vcpu-hour: 0.01, gib-hour: 0.001}
+clusters:
+  prod-eu:
+    rates:
+      on-demand: {vcpu-hour: 0.05, gib-hour: 0.006}
+  dev:
+    capacity: spot
+node-pools:
+  - name: batch-spot
+    cluster: "prod-.*"
+    match: {namespace: "batch-.*"}
+    capacity: spot
+  - name: never
+    match: {namespace: "batch-.*"}
+    capacity: on-demand
+`
+
+func TestFor(t *testing.T) {
+	p, err := Parse([]byte(testPricing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Meta.Capacity != OnDemand {
+		t.Errorf("default capacity = %q, want %q", p.Meta.Capacity, OnDemand)
+	}
+
+	tests := []struct {
+		cluster, namespace string
+		rate               Rate
+		tier               string
+	}{
+		{cluster: "other", namespace: "shop", rate: Rate{0.04, 0.005}, tier: "on-demand"},
+		{cluster: "prod-eu", namespace: "shop", rate: Rate{0.05, 0.006}, tier: "on-demand"},
+		{cluster: "dev", namespace: "shop", rate: Rate{0.01, 0.001}, tier: "spot"},
+		// The first matching node pool wins
+		{cluster: "prod-eu", namespace: "batch-nightly", rate: Rate{0.01, 0.001}, tier: "batch-spot/spot"},
+		{cluster: "dev", namespace: "batch-nightly", rate: Rate{0.04, 0.005}, tier: "never/on-demand"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cluster+"/"+tt.namespace, func(t *testing.T) {
+			rate, tier := p.For(tt.cluster, tt.namespace, "", "api")
+			if rate != tt.rate || tier != tt.tier {
+				t.Errorf("For = %+v %q, want %+v %q", rate, tier, tt.rate, tt.tier)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidPricing(t *testing.T) {
+	tests := map[string]string{
+		"no rates":          "capacity: on-demand\n",
+		"unknown capacity":  "capacity: reserved\nrates:\n  on-demand: {vcpu-hour: 0.1}\n",
+		"negative rate":     "rates:\n  on-demand: {vcpu-hour: -0.1}\n",
+		"unknown key":       "rates:\n  on-demand: {cpu-hour: 0.1}\n",
+		"cluster w/o spot":  "rates:\n  on-demand: {vcpu-hour: 0.1}\nclusters:\n  dev: {capacity: spot}\n",
+		"pool w/o pattern":  "rates:\n  on-demand: {vcpu-hour: 0.1}\nnode-pools:\n  - capacity: on-demand\n",
+		"pool bad regexp":   "rates:\n  on-demand: {vcpu-hour: 0.1}\nnode-pools:\n  - cluster: \"(\"\n",
+		"pool w/o its rate": "rates:\n  on-demand: {vcpu-hour: 0.1}\nnode-pools:\n  - cluster: prod\n    capacity: spot\n",
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(doc)); err == nil {
+				t.Errorf("Parse succeeded, want an error for:\n%s", doc)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expr is a PromQL expression node. Every node renders itself with all
+// user-supplied values escaped, so no input can change the query shape.
+type Expr interface {
+	String() string
+}
+
+// ---------------------------------------------------------------------
+// Durations
+// ---------------------------------------------------------------------
+
+// Duration is a validated PromQL duration literal (e.g. 5m, 1h30m, 7d).
+type Duration string
+
+var durationRE = regexp.MustCompile(`^([0-9]+(ms|s|m|h|d|w|y))+$`)
+
+func ParseDuration(s string) (Duration, error) {
+	if !durationRE.MatchString(s) {
+		return "", fmt.Errorf("invalid duration %q (want e.g. 30s, 5m, 24h, 7d)", s)
+	}
+	return Duration(s), nil
+}
+
+// MustDuration is ParseDuration for constants; it panics on invalid input.
+func MustDuration(s string) Duration {
+	d, err := ParseDuration(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// ---------------------------------------------------------------------
+// Selectors and matchers
+// ---------------------------------------------------------------------
+
+type MatchOp string
+
+const (
+	MatchEqual     MatchOp = "="
+	MatchNotEqual  MatchOp = "!="
+	MatchRegexp    MatchOp = "=~"
+	MatchNotRegexp MatchOp = "!~"
+)
+
+type LabelMatcher struct {
+	Name  string
+	Op    MatchOp
+	Value string
+}
+
+func Eq(name, value string) LabelMatcher  { return LabelMatcher{name, MatchEqual, value} }
+func Neq(name, value string) LabelMatcher { return LabelMatcher{name, MatchNotEqual, value} }
+
+// Re and NotRe take an RE2 pattern (anchored by PromQL); see ValidateRegexp.
+func Re(name, pattern string) LabelMatcher    { return LabelMatcher{name, MatchRegexp, pattern} }
+func NotRe(name, pattern string) LabelMatcher { return LabelMatcher{name, MatchNotRegexp, pattern} }
+
+// ValidateRegexp checks that a pattern compiles with the RE2 syntax PromQL uses.
+func ValidateRegexp(pattern string) error {
+	if _, err := regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+		return fmt.Errorf("invalid regexp %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// QuoteRegexp escapes a literal so it matches only itself inside =~.
+func QuoteRegexp(s string) string {
+	return regexp.QuoteMeta(s)
+}
+
+func (m LabelMatcher) String() string {
+	return mustLabelName(m.Name) + string(m.Op) + quote(m.Value)
+}
+
+// Selector is an instant vector selector: metric{matchers...}.
+type Selector struct {
+	Metric   string
+	Matchers []LabelMatcher
+}
+
+func Select(metric string, matchers ...LabelMatcher) Selector {
+	return Selector{Metric: metric, Matchers: matchers}
+}
+
+// With returns a copy of the selector with extra matchers appended.
+func (s Selector) With(matchers ...LabelMatcher) Selector {
+	out := Selector{Metric: s.Metric}
+	out.Matchers = append(append(out.Matchers, s.Matchers...), matchers...)
+	return out
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s.Matchers))
+	for i, m := range s.Matchers {
+		parts[i] = m.String()
+	}
+	return mustMetricName(s.Metric) + "{" + strings.Join(parts, ",") + "}"
+}
+
+// Range turns a selector into a range vector selector: metric{...}[d].
+func (s Selector) Range(d Duration) Expr {
+	return rangeSelector{sel: s, d: d}
+}
+
+type rangeSelector struct {
+	sel Selector
+	d   Duration
+}
+
+func (r rangeSelector) String() string {
+	return r.sel.String() + "[" + string(r.d) + "]"
+}
+
+// ---------------------------------------------------------------------
+// Functions, aggregations, operators, subqueries
+// ---------------------------------------------------------------------
+
+type call struct {
+	fn   string
+	args []Expr
+}
+
+func (c call) String() string {
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		args[i] = a.String()
+	}
+	return c.fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Call invokes a PromQL function by name.
+func Call(fn string, args ...Expr) Expr {
+	return call{fn: mustIdent(fn), args: args}
+}
+
+func Rate(r Expr) Expr          { return Call("rate", r) }
+func Increase(r Expr) Expr      { return Call("increase", r) }
+func MaxOverTime(r Expr) Expr   { return Call("max_over_time", r) }
+func AvgOverTime(r Expr) Expr   { return Call("avg_over_time", r) }
+func CountOverTime(r Expr) Expr { return Call("count_over_time", r) }
+
+func QuantileOverTime(q float64, r Expr) Expr {
+	return Call("quantile_over_time", Num(q), r)
+}
+
+// LabelReplace is label_replace(e, dst, replacement, src, regex).
+func LabelReplace(e Expr, dst, replacement, src, regex string) Expr {
+	return Call("label_replace", e, str(mustLabelName(dst)), str(replacement), str(mustLabelName(src)), str(regex))
+}
+
+// str is a string literal argument.
+type str string
+
+func (s str) String() string { return quote(string(s)) }
+
+type number float64
+
+func (n number) String() string {
+	return strconv.FormatFloat(float64(n), 'f', -1, 64)
+}
+
+func Num(f float64) Expr { return number(f) }
+
+type aggregation struct {
+	op    string
+	by    []string
+	param Expr
+	expr  Expr
+}
+
+func (a aggregation) String() string {
+	var b strings.Builder
+	b.WriteString(a.op)
+	if len(a.by) > 0 {
+		names := make([]string, len(a.by))
+		for i, n := range a.by {
+			names[i] = mustLabelName(n)
+		}
+		b.WriteString(" by (" + strings.Join(names, ", ") + ")")
+	}
+	b.WriteString(" (")
+	if a.param != nil {
+		b.WriteString(a.param.String() + ", ")
+	}
+	b.WriteString(a.expr.String() + ")")
+	return b.String()
+}
+
+// Aggregate applies an aggregation operator grouped by the given labels.
+func Aggregate(op string, expr Expr, by ...string) Expr {
+	return aggregation{op: mustIdent(op), by: by, expr: expr}
+}
+
+func SumBy(expr Expr, by ...string) Expr { return Aggregate("sum", expr, by...) }
+func AvgBy(expr Expr, by ...string) Expr { return Aggregate("avg", expr, by...) }
+func MaxBy(expr Expr, by ...string) Expr { return Aggregate("max", expr, by...) }
+func MinBy(expr Expr, by ...string) Expr { return Aggregate("min", expr, by...) }
+
+// QuantileBy is the quantile aggregation operator (not quantile_over_time).
+func QuantileBy(q float64, expr Expr, by ...string) Expr {
+	return aggregation{op: "quantile", by: by, param: Num(q), expr: expr}
+}
+
+type binary struct {
+	op          string
+	left, right Expr
+	matching    string
+	grouping    string
+}
+
+func (b binary) String() string {
+	op := b.op
+	if b.matching != "" {
+		op += " " + b.matching
+	}
+	if b.grouping != "" {
+		op += " " + b.grouping
+	}
+	return "(" + b.left.String() + ") " + op + " (" + b.right.String() + ")"
+}
+
+func Div(a, b Expr) Expr { return binary{op: "/", left: a, right: b} }
+func Mul(a, b Expr) Expr { return binary{op: "*", left: a, right: b} }
+func Add(a, b Expr) Expr { return binary{op: "+", left: a, right: b} }
+func Sub(a, b Expr) Expr { return binary{op: "-", left: a, right: b} }
+func Or(a, b Expr) Expr  { return binary{op: "or", left: a, right: b} }
+func And(a, b Expr) Expr { return binary{op: "and", left: a, right: b} }
+
+// On restricts vector matching of a binary expression to the given labels.
+func On(e Expr, labels ...string) Expr {
+	b := mustBinary(e, "On")
+	b.matching = "on (" + labelList(labels) + ")"
+	return b
+}
+
+// GroupLeft makes a binary expression many-to-one, copying the given
+// labels from the right-hand side onto each left-hand series.
+func GroupLeft(e Expr, labels ...string) Expr {
+	b := mustBinary(e, "GroupLeft")
+	b.grouping = "group_left (" + labelList(labels) + ")"
+	return b
+}
+
+func mustBinary(e Expr, fn string) binary {
+	b, ok := e.(binary)
+	if !ok {
+		panic("promql: " + fn + " applied to a non-binary expression")
+	}
+	return b
+}
+
+func labelList(labels []string) string {
+	names := make([]string, len(labels))
+	for i, n := range labels {
+		names[i] = mustLabelName(n)
+	}
+	return strings.Join(names, ", ")
+}
+
+type subquery struct {
+	expr      Expr
+	rng, step Duration
+}
+
+func (s subquery) String() string {
+	return "(" + s.expr.String() + ")[" + string(s.rng) + ":" + string(s.step) + "]"
+}
+
+// Subquery evaluates expr over rng at the given resolution: (expr)[rng:step].
+func Subquery(expr Expr, rng, step Duration) Expr {
+	return subquery{expr: expr, rng: rng, step: step}
+}
+
+// ---------------------------------------------------------------------
+// Escaping and identifier validation
+// ---------------------------------------------------------------------
+
+// quote renders a PromQL double-quoted string literal. PromQL string
+// escapes follow Go's, so strconv.Quote is exact.
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+var (
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+)
+
+// ValidLabelName reports whether s can be used as a label name.
+func ValidLabelName(s string) bool { return labelNameRE.MatchString(s) }
+
+// Label and metric names are identifiers in PromQL and cannot be quoted, so
+// invalid ones are programming/config errors: callers validate config
+// up front with ValidLabelName, and anything reaching here panics.
+func mustLabelName(s string) string {
+	if !labelNameRE.MatchString(s) {
+		panic(fmt.Sprintf("promql: invalid label name %q", s))
+	}
+	return s
+}
+
+func mustMetricName(s string) string {
+	if !metricNameRE.MatchString(s) {
+		panic(fmt.Sprintf("promql: invalid metric name %q", s))
+	}
+	return s
+}
+
+func mustIdent(s string) string {
+	if !labelNameRE.MatchString(s) {
+		panic(fmt.Sprintf("promql: invalid identifier %q", s))
+	}
+	return s
+}
@@ -0,0 +1,405 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMatcherEscaping(t *testing.T) {
+	values := []string{
+		`plain`,
+		`quo"te`,
+		`back\slash`,
+		"new\nline",
+		`a"} or vector(1) #`,
+		`.*|a+b?(c)[d]{2}^$`,
+		`ünïcode`,
+	}
+
+	for _, v := range values {
+		for _, m := range []LabelMatcher{Eq("namespace", v), Neq("namespace", v), Re("namespace", v), NotRe("namespace", v)} {
+			t.Run(string(m.Op)+v, func(t *testing.T) {
+				sel := Select("up", m).String()
+				if err := checkPromQL(sel); err != nil {
+					t.Fatalf("%s: %v", sel, err)
+				}
+				prefix := "up{namespace" + string(m.Op)
+				if !strings.HasPrefix(sel, prefix) || !strings.HasSuffix(sel, "}") {
+					t.Fatalf("%s: want a single namespace matcher", sel)
+				}
+				got, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(sel, prefix), "}"))
+				if err != nil {
+					t.Fatalf("%s: value is not one string literal: %v", sel, err)
+				}
+				if got != v {
+					t.Errorf("%s: value = %q, want %q", sel, got, v)
+				}
+			})
+		}
+	}
+}
+
+func TestQuoteRegexpMatchesLiteral(t *testing.T) {
+	for _, v := range []string{`a.b`, `team-(x)`, `a|b`, `c++`, `back\slash`, `$^[]{}?*`} {
+		m := Re("namespace", QuoteRegexp(v))
+		if err := ValidateRegexp(m.Value); err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+
+		// PromQL unquotes the literal, then anchors the pattern
+		lit := strings.TrimPrefix(m.String(), "namespace=~")
+		pattern, err := strconv.Unquote(lit)
+		if err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+		re := regexp.MustCompile("^(?:" + pattern + ")$")
+		if !re.MatchString(v) {
+			t.Errorf("%s does not match %q", m, v)
+		}
+		if re.MatchString(v + "x") {
+			t.Errorf("%s matches more than %q", m, v)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "30s"},
+		{in: "5m"},
+		{in: "1h30m"},
+		{in: "7d"},
+		{in: "250ms"},
+		{in: "", wantErr: true},
+		{in: "5x", wantErr: true},
+		{in: "-1m", wantErr: true},
+		{in: "5", wantErr: true},
+		{in: "m", wantErr: true},
+		{in: "1.5h", wantErr: true},
+		{in: "5m]", wantErr: true},
+		{in: " 5m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDuration(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration(%q) = %q, %v; want error %v", tt.in, d, err, tt.wantErr)
+			}
+			if !tt.wantErr && string(d) != tt.in {
+				t.Errorf("ParseDuration(%q) = %q", tt.in, d)
+			}
+		})
+	}
+}
+
+func TestLabelSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  LabelSchema
+		wantErr bool
+	}{
+		{name: "defaults", schema: LabelSchema{}.WithDefaults()},
+		{name: "extra and required", schema: LabelSchema{Cluster: "cluster", Extra: []string{"pod_owner"}, Required: map[string]string{"env": `pr"od`}}},
+		{name: "empty cluster", schema: LabelSchema{}, wantErr: true},
+		{name: "dash in cluster", schema: LabelSchema{Cluster: "uw-cluster"}, wantErr: true},
+		{name: "leading digit", schema: LabelSchema{Cluster: "uw_cluster", Extra: []string{"1owner"}}, wantErr: true},
+		{name: "colon in label", schema: LabelSchema{Cluster: "uw_cluster", Extra: []string{"a:b"}}, wantErr: true},
+		{name: "injected required name", schema: LabelSchema{Cluster: "uw_cluster", Required: map[string]string{`env="x",y`: "prod"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schema.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamespaceSelectorValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sel     NamespaceSelector
+		wantErr bool
+	}{
+		{name: "name", sel: Namespace("shop")},
+		{name: "include", sel: NamespaceSelector{Include: "team-.*"}},
+		{name: "all minus exclude", sel: NamespaceSelector{All: true, Exclude: "kube-.*"}},
+		{name: "nothing selected", sel: NamespaceSelector{}, wantErr: true},
+		{name: "bad include", sel: NamespaceSelector{Include: "team-("}, wantErr: true},
+		{name: "bad exclude", sel: NamespaceSelector{All: true, Exclude: "[a-"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sel.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvalidIdentifiersPanic(t *testing.T) {
+	tests := map[string]func() string{
+		"label name":  func() string { return Select("up", Eq("bad-name", "x")).String() },
+		"metric name": func() string { return Select("up{}", Eq("job", "x")).String() },
+		"by label":    func() string { return SumBy(Select("up"), "a b").String() },
+		"function":    func() string { return Call("rate(", Select("up")).String() },
+	}
+
+	for name, render := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			t.Errorf("rendered %s", render())
+		})
+	}
+}
+
+func TestRightsizeQueriesAreValid(t *testing.T) {
+	window, step := MustDuration("7d"), MustDuration("5m")
+	schemas := map[string]LabelSchema{
+		"defaults":  LabelSchema{}.WithDefaults(),
+		"workloads": LabelSchema{Workloads: true, Extra: []string{"pod_owner"}, Required: map[string]string{"env": `pr"od`, "region": "eu\\1"}}.WithDefaults(),
+	}
+	selectors := map[string]NamespaceSelector{
+		"one":     Namespace(`sh"op`),
+		"names":   {Names: []string{"a.b", "c|d"}},
+		"include": {Include: "team-.*", Exclude: "team-(x|y)"},
+		"all":     {All: true},
+	}
+
+	for sname, schema := range schemas {
+		q := NewRightsizeQueries(schema)
+		for nname, ns := range selectors {
+			queries := map[string]string{
+				"ClusterNames":    q.ClusterNames(ns, "prod-.*"),
+				"MemRatio":        q.MemRatio(ns, "c1", StatP95, window, step),
+				"CpuRatio":        q.CpuRatio(ns, "c1", StatMax, window, step),
+				"MemUsage":        q.MemUsage(ns, "c1", Stat("p99.9"), window, step),
+				"CpuUsage":        q.CpuUsage(ns, "c1", StatP95, window, step),
+				"Coverage":        q.Coverage(ns, "c1", window, step),
+				"PodAge":          q.PodAge(ns, "c1"),
+				"Restarts":        q.Restarts(ns, "c1", window),
+				"Replicas":        q.Replicas(ns, "c1"),
+				"ReplicasAvg":     q.ReplicasAvg(ns, "c1", window, step),
+				"ReplicasPeak":    q.ReplicasPeak(ns, "c1", window, step),
+				"MemRequests":     q.MemRequests(ns, "c1"),
+				"CpuRequests":     q.CpuRequests(ns, "c1"),
+				"MemLimits":       q.MemLimits(ns, "c1"),
+				"CpuLimits":       q.CpuLimits(ns, "c1"),
+				"MemPeak":         q.MemPeak(ns, "c1", window),
+				"CpuPeak":         q.CpuPeak(ns, "c1", window, step),
+				"InitMemRequests": q.InitMemRequests(ns, "c1"),
+				"InitCpuRequests": q.InitCpuRequests(ns, "c1"),
+				"InitMemPeak":     q.InitMemPeak(ns, "c1", window),
+				"InitCpuPeak":     q.InitCpuPeak(ns, "c1", window, step),
+				"OOMKilled":       q.OOMKilled(ns, "c1", window),
+				"CPUThrottling":   q.CPUThrottling(ns, "c1", window),
+				"JVMHeapAfterGC":  q.JVMHeapAfterGC(ns, "c1"),
+				"JVMNonHeapBytes": q.JVMNonHeapBytes(ns, "c1"),
+			}
+			for name, expr := range queries {
+				if err := checkPromQL(expr); err != nil {
+					t.Errorf("%s/%s/%s: %v\n%s", sname, nname, name, err, expr)
+				}
+			}
+		}
+	}
+}
+
+// checkPromQL is a structural check of the expressions the builder emits:
+// string literals unquote, brackets balance, selectors are lists of
+// name-op-string matchers and ranges hold durations. It is not a full
+// PromQL parser, but anything the builder could mis-escape fails it.
+func checkPromQL(s string) error {
+	toks, err := lex(s)
+	if err != nil {
+		return err
+	}
+
+	var stack []string
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		switch tok.text {
+		case "(", "[":
+			stack = append(stack, tok.text)
+			if tok.text == "[" {
+				n, err := checkRange(toks[i+1:])
+				if err != nil {
+					return err
+				}
+				i += n
+				stack = stack[:len(stack)-1]
+			}
+		case ")", "]":
+			want := map[string]string{")": "(", "]": "["}[tok.text]
+			if len(stack) == 0 || stack[len(stack)-1] != want {
+				return fmt.Errorf("unbalanced %q at offset %d", tok.text, tok.pos)
+			}
+			stack = stack[:len(stack)-1]
+		case "{":
+			if i == 0 || toks[i-1].kind != tokIdent {
+				return fmt.Errorf("selector without a metric name at offset %d", tok.pos)
+			}
+			n, err := checkMatchers(toks[i+1:])
+			if err != nil {
+				return err
+			}
+			i += n
+		case "}":
+			return fmt.Errorf("unbalanced } at offset %d", tok.pos)
+		}
+		if tok.kind == tokString && (i == 0 || toks[i-1].text != "," && toks[i-1].text != "(") {
+			return fmt.Errorf("string literal outside a selector or argument list at offset %d", tok.pos)
+		}
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("unclosed %q", stack[len(stack)-1])
+	}
+	return nil
+}
+
+// checkMatchers checks `name op "value" (, name op "value")* }` and returns
+// the number of tokens consumed.
+func checkMatchers(toks []token) (int, error) {
+	for i := 0; i < len(toks); {
+		if toks[i].text == "}" {
+			return i + 1, nil
+		}
+		if i+2 >= len(toks) || toks[i].kind != tokIdent || toks[i+1].kind != tokOp || toks[i+2].kind != tokString {
+			return 0, fmt.Errorf("malformed matcher at offset %d", toks[i].pos)
+		}
+		switch toks[i+1].text {
+		case "=", "!=", "=~", "!~":
+		default:
+			return 0, fmt.Errorf("bad matcher operator %q", toks[i+1].text)
+		}
+		i += 3
+		if i < len(toks) && toks[i].text == "," {
+			i++
+		}
+	}
+	return 0, fmt.Errorf("unclosed selector")
+}
+
+// checkRange checks `duration (: duration)? ]`.
+func checkRange(toks []token) (int, error) {
+	var parts []string
+	for i, tok := range toks {
+		switch {
+		case tok.text == "]":
+			if len(parts) == 0 || len(parts) > 2 {
+				return 0, fmt.Errorf("bad range at offset %d", tok.pos)
+			}
+			for _, p := range parts {
+				if _, err := ParseDuration(p); err != nil {
+					return 0, err
+				}
+			}
+			return i + 1, nil
+		case tok.text == ":":
+		case tok.kind == tokDuration:
+			parts = append(parts, tok.text)
+		default:
+			return 0, fmt.Errorf("unexpected %q in range", tok.text)
+		}
+	}
+	return 0, fmt.Errorf("unclosed range")
+}
+
+type tokKind int
+
+const (
+	tokIdent tokKind = iota
+	tokString
+	tokNumber
+	tokDuration
+	tokOp
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+var (
+	identTok    = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*`)
+	durationTok = regexp.MustCompile(`^([0-9]+(ms|s|m|h|d|w|y))+`)
+	numberTok   = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?`)
+	opTok       = regexp.MustCompile(`^(=~|!~|!=|==|=|[-+*/])`)
+)
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch c := s[i]; {
+		case c == ' ':
+			i++
+		case c == '"':
+			end := stringEnd(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			lit := rest[:end]
+			if strings.ContainsAny(lit, "\n\r") {
+				return nil, fmt.Errorf("raw newline in string at offset %d", i)
+			}
+			if _, err := strconv.Unquote(lit); err != nil {
+				return nil, fmt.Errorf("bad string %s: %w", lit, err)
+			}
+			toks = append(toks, token{tokString, lit, i})
+			i += end
+		case strings.IndexByte("(){}[],", c) >= 0:
+			toks = append(toks, token{tokPunct, string(c), i})
+			i++
+		case c == ':' && (len(toks) > 0 && toks[len(toks)-1].kind == tokDuration):
+			toks = append(toks, token{tokPunct, ":", i})
+			i++
+		default:
+			var kind tokKind
+			var m string
+			switch {
+			case durationTok.MatchString(rest):
+				kind, m = tokDuration, durationTok.FindString(rest)
+			case numberTok.MatchString(rest):
+				kind, m = tokNumber, numberTok.FindString(rest)
+			case identTok.MatchString(rest):
+				kind, m = tokIdent, identTok.FindString(rest)
+			case opTok.MatchString(rest):
+				kind, m = tokOp, opTok.FindString(rest)
+			default:
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			toks = append(toks, token{kind, m, i})
+			i += len(m)
+		}
+	}
+	return toks, nil
+}
+
+// stringEnd returns the length of the double-quoted literal at the start
+// of s, or -1 if it is not terminated.
+func stringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
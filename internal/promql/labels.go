@@ -0,0 +1,103 @@
+package promql
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const DefaultClusterLabel = "uw_cluster"
+
+// Labels added to every series when results are keyed by workload.
+const (
+	WorkloadLabel     = "workload"
+	WorkloadKindLabel = "workload_kind"
+)
+
+// LabelSchema describes how series are labelled in the TSDB: which label
+// carries the cluster name, which labels identify a result row, and which
+// external labels every selector must carry.
+type LabelSchema struct {
+	// Cluster is the cluster label name (default uw_cluster).
+	Cluster string
+
+	// Extra identity labels beyond namespace/container/cluster
+	// (e.g. pod_owner, workload). Results are keyed by all identity labels.
+	Extra []string
+
+	// Required external labels added as equality matchers to every selector.
+	Required map[string]string
+
+	// Workloads keys results by owning workload (Deployment, StatefulSet, ...)
+	// as well as container, by joining pods to kube_pod_owner.
+	Workloads bool
+}
+
+// WithDefaults fills unset fields.
+func (l LabelSchema) WithDefaults() LabelSchema {
+	if l.Cluster == "" {
+		l.Cluster = DefaultClusterLabel
+	}
+	return l
+}
+
+func (l LabelSchema) Validate() error {
+	if !ValidLabelName(l.Cluster) {
+		return fmt.Errorf("invalid cluster label name %q", l.Cluster)
+	}
+	for _, n := range l.Extra {
+		if !ValidLabelName(n) {
+			return fmt.Errorf("invalid identity label name %q", n)
+		}
+	}
+	for n := range l.Required {
+		if !ValidLabelName(n) {
+			return fmt.Errorf("invalid required label name %q", n)
+		}
+	}
+	return nil
+}
+
+// Identity returns the labels results are grouped and joined by.
+func (l LabelSchema) Identity() []string {
+	out := []string{"namespace", "container", l.Cluster}
+	if l.Workloads {
+		out = append(out, WorkloadKindLabel, WorkloadLabel)
+	}
+	for _, n := range l.Extra {
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// scope selects the namespaces in one cluster, plus the required labels.
+func (l LabelSchema) scope(ns NamespaceSelector, cluster string) []LabelMatcher {
+	return l.scopeMatching(ns, Eq(l.Cluster, cluster))
+}
+
+// scopeMatching is scope with an arbitrary cluster matcher.
+func (l LabelSchema) scopeMatching(ns NamespaceSelector, cluster LabelMatcher) []LabelMatcher {
+	out := append(ns.matchers(), cluster)
+
+	names := make([]string, 0, len(l.Required))
+	for n := range l.Required {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		out = append(out, Eq(n, l.Required[n]))
+	}
+	return out
+}
+
+// ReplicaLabels are the labels replica counts are keyed by: the workload
+// when results are keyed by workload (its containers share one count),
+// otherwise the full identity.
+func (l LabelSchema) ReplicaLabels() []string {
+	if !l.Workloads {
+		return l.Identity()
+	}
+	return []string{"namespace", l.Cluster, WorkloadKindLabel, WorkloadLabel}
+}
@@ -0,0 +1,49 @@
+package promql
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLabelSchemaIdentity(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema LabelSchema
+		want   []string
+	}{
+		{name: "defaults", schema: LabelSchema{}.WithDefaults(), want: []string{"namespace", "container", "uw_cluster"}},
+		{name: "custom cluster", schema: LabelSchema{Cluster: "cluster"}, want: []string{"namespace", "container", "cluster"}},
+		{name: "extra deduplicated", schema: LabelSchema{Cluster: "cluster", Extra: []string{"pod_owner", "container"}}, want: []string{"namespace", "container", "cluster", "pod_owner"}},
+		{name: "workloads", schema: LabelSchema{Cluster: "cluster", Workloads: true}, want: []string{"namespace", "container", "cluster", WorkloadKindLabel, WorkloadLabel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schema.Identity(); !slices.Equal(got, tt.want) {
+				t.Errorf("Identity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelSchemaScopesEverySelector(t *testing.T) {
+	schema := LabelSchema{Cluster: "k8s_cluster", Required: map[string]string{"region": "eu", "env": "prod"}}
+	q := NewRightsizeQueries(schema)
+	window, step := MustDuration("7d"), MustDuration("5m")
+
+	for name, expr := range map[string]string{
+		"MemRatio":    q.MemRatio(Namespace("shop"), "c1", StatP95, window, step),
+		"CpuRequests": q.CpuRequests(Namespace("shop"), "c1"),
+		"OOMKilled":   q.OOMKilled(Namespace("shop"), "c1", window),
+	} {
+		selectors := strings.Count(expr, "{")
+		// Required labels are added in name order after the cluster
+		if n := strings.Count(expr, `k8s_cluster="c1",env="prod",region="eu"`); n != selectors {
+			t.Errorf("%s: %d of %d selectors are scoped:\n%s", name, n, selectors, expr)
+		}
+		if strings.Contains(expr, DefaultClusterLabel) {
+			t.Errorf("%s: uses the default cluster label:\n%s", name, expr)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamespaceSelector picks the namespaces a query covers: explicit names,
+// an include regex, or all of them, minus an optional exclude regex.
+// All and Include take precedence over Names.
+type NamespaceSelector struct {
+	Names   []string
+	Include string
+	Exclude string
+	All     bool
+}
+
+// Namespace selects exactly one namespace.
+func Namespace(name string) NamespaceSelector {
+	return NamespaceSelector{Names: []string{name}}
+}
+
+func (n NamespaceSelector) Validate() error {
+	if !n.All && n.Include == "" && len(n.Names) == 0 {
+		return fmt.Errorf("no namespace selected (use --namespace, --namespace-regex or --all-namespaces)")
+	}
+	for _, re := range []string{n.Include, n.Exclude} {
+		if re == "" {
+			continue
+		}
+		if err := ValidateRegexp(re); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n NamespaceSelector) matchers() []LabelMatcher {
+	var out []LabelMatcher
+	switch {
+	case n.All && n.Include == "":
+		// no include matcher: every namespace
+	case n.Include != "":
+		out = append(out, Re("namespace", n.Include))
+	case len(n.Names) == 1:
+		out = append(out, Eq("namespace", n.Names[0]))
+	default:
+		quoted := make([]string, len(n.Names))
+		for i, name := range n.Names {
+			quoted[i] = QuoteRegexp(name)
+		}
+		out = append(out, Re("namespace", strings.Join(quoted, "|")))
+	}
+	if n.Exclude != "" {
+		out = append(out, NotRe("namespace", n.Exclude))
+	}
+	return out
+}
+
+// String describes the selection for reports (e.g. "a,b", "=~team-.*", "*").
+func (n NamespaceSelector) String() string {
+	var s string
+	switch {
+	case n.Include != "":
+		s = "=~" + n.Include
+	case n.All:
+		s = "*"
+	default:
+		s = strings.Join(n.Names, ",")
+	}
+	if n.Exclude != "" {
+		s += " !~" + n.Exclude
+	}
+	return s
+}
@@ -0,0 +1,30 @@
+package promql
+
+import "testing"
+
+func TestNamespaceSelectorMatchers(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  NamespaceSelector
+		want string
+		desc string
+	}{
+		{name: "one", sel: Namespace("shop"), want: `up{namespace="shop"}`, desc: "shop"},
+		{name: "names are literals", sel: NamespaceSelector{Names: []string{"a.b", "c"}}, want: `up{namespace=~"a\\.b|c"}`, desc: "a.b,c"},
+		{name: "include wins over names", sel: NamespaceSelector{Names: []string{"x"}, Include: "team-.*"}, want: `up{namespace=~"team-.*"}`, desc: "=~team-.*"},
+		{name: "all", sel: NamespaceSelector{All: true}, want: `up{}`, desc: "*"},
+		{name: "all minus exclude", sel: NamespaceSelector{All: true, Exclude: "kube-.*"}, want: `up{namespace!~"kube-.*"}`, desc: "* !~kube-.*"},
+		{name: "names minus exclude", sel: NamespaceSelector{Names: []string{"a"}, Exclude: "b"}, want: `up{namespace="a",namespace!~"b"}`, desc: "a !~b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Select("up", tt.sel.matchers()...).String(); got != tt.want {
+				t.Errorf("selector = %s, want %s", got, tt.want)
+			}
+			if got := tt.sel.String(); got != tt.desc {
+				t.Errorf("String = %q, want %q", got, tt.desc)
+			}
+		})
+	}
+}
@@ -1,110 +1,277 @@
 package promql
 
-import "fmt"
-
-// p95 over time of mem usage/request, aggregated at service-level (container)
-func MemP95Ratio(namespace, cluster, window, subStep string) string {
-	// Use subquery so quantile_over_time works over the evaluated ratio over time
-	return fmt.Sprintf(`
-quantile_over_time(
-  0.95,
-  (
-    avg by (namespace, container, uw_cluster) (
-      container_memory_working_set_bytes{namespace="%s",uw_cluster="%s"}
-    )
-    /
-    avg by (namespace, container, uw_cluster) (
-      kube_pod_container_resource_requests{namespace="%s",resource="memory",uw_cluster="%s"}
-    )
-  )[%s:%s]
-)
-`, namespace, cluster, namespace, cluster, window, subStep)
-}
-
-func CpuP95Ratio(namespace, cluster, window, subStep string) string {
+// RightsizeQueries builds the rightsize signal queries for a label schema.
+// All results are grouped by the schema's identity labels so the service
+// can join them per container.
+type RightsizeQueries struct {
+	Labels LabelSchema
+}
+
+func NewRightsizeQueries(labels LabelSchema) RightsizeQueries {
+	return RightsizeQueries{Labels: labels}
+}
+
+// realContainers drops the pause container and pod-level cgroup series.
+func realContainers() []LabelMatcher {
+	return []LabelMatcher{Neq("container", "POD"), Neq("container", "")}
+}
+
+// by aggregates per-pod series to one series per identity, attaching the
+// owning workload first when the schema keys results by workload.
+func (q RightsizeQueries) by(e Expr, ns NamespaceSelector, cluster string, agg func(Expr, ...string) Expr) Expr {
+	return agg(q.owned(e, ns, cluster, ""), q.Labels.Identity()...)
+}
+
+// byOver is by for per-pod values computed over a window (peaks, restarts,
+// OOM kills): pods are matched to owners they had at any point in it, so
+// pods replaced since still count.
+func (q RightsizeQueries) byOver(e Expr, ns NamespaceSelector, cluster string, window Duration, agg func(Expr, ...string) Expr) Expr {
+	return agg(q.owned(e, ns, cluster, window), q.Labels.Identity()...)
+}
+
+func (q RightsizeQueries) requests(ns NamespaceSelector, cluster, resource string) Selector {
+	return Select("kube_pod_container_resource_requests",
+		append(q.Labels.scope(ns, cluster), Eq("resource", resource))...,
+	)
+}
+
+// ClusterNames lists the clusters matching a regex that have container
+// requests in the selected namespaces (one sample per cluster).
+func (q RightsizeQueries) ClusterNames(ns NamespaceSelector, clusterRegex string) string {
+	sel := Select("kube_pod_container_resource_requests",
+		q.Labels.scopeMatching(ns, Re(q.Labels.Cluster, clusterRegex))...,
+	)
+	return Aggregate("count", sel, q.Labels.Cluster).String()
+}
+
+// stat (e.g. p95) over time of mem usage/request, aggregated at service-level (container)
+func (q RightsizeQueries) MemRatio(ns NamespaceSelector, cluster string, stat Stat, window, subStep Duration) string {
+	// Use subquery so the statistic works over the evaluated ratio over time
+	usage := Select("container_memory_working_set_bytes", q.Labels.scope(ns, cluster)...)
+
+	return stat.Over(Subquery(
+		Div(
+			q.by(usage, ns, cluster, AvgBy),
+			q.by(q.requests(ns, cluster, "memory"), ns, cluster, AvgBy),
+		),
+		window, subStep,
+	)).String()
+}
+
+func (q RightsizeQueries) CpuRatio(ns NamespaceSelector, cluster string, stat Stat, window, subStep Duration) string {
 	// CPU usage in cores = rate(cpu_seconds_total[5m])
-	return fmt.Sprintf(`
-quantile_over_time(
-  0.95,
-  (
-    avg by (namespace, container, uw_cluster) (
-      rate(container_cpu_usage_seconds_total{namespace="%s",uw_cluster="%s",container!="POD",container!=""}[5m])
-    )
-    /
-    avg by (namespace, container, uw_cluster) (
-      kube_pod_container_resource_requests{namespace="%s",resource="cpu",uw_cluster="%s"}
-    )
-  )[%s:%s]
-)
-`, namespace, cluster, namespace, cluster, window, subStep)
-}
-
-func MemRequests(namespace, cluster string) string {
-	return fmt.Sprintf(`
-avg by (namespace, container, uw_cluster) (
-  kube_pod_container_resource_requests{namespace="%s",resource="memory",uw_cluster="%s"}
-)
-`, namespace, cluster)
-}
-
-func CpuRequests(namespace, cluster string) string {
-	return fmt.Sprintf(`
-avg by (namespace, container, uw_cluster) (
-  kube_pod_container_resource_requests{namespace="%s",resource="cpu",uw_cluster="%s"}
-)
-`, namespace, cluster)
+	usage := Select("container_cpu_usage_seconds_total",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+
+	return stat.Over(Subquery(
+		Div(
+			q.by(Rate(usage.Range(MustDuration("5m"))), ns, cluster, AvgBy),
+			q.by(q.requests(ns, cluster, "cpu"), ns, cluster, AvgBy),
+		),
+		window, subStep,
+	)).String()
+}
+
+// MemUsage and CpuUsage are the stat over time of absolute usage (bytes,
+// cores), averaged over a service's pods; they do not depend on requests.
+func (q RightsizeQueries) MemUsage(ns NamespaceSelector, cluster string, stat Stat, window, subStep Duration) string {
+	usage := Select("container_memory_working_set_bytes",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+	return stat.Over(Subquery(q.by(usage, ns, cluster, AvgBy), window, subStep)).String()
+}
+
+func (q RightsizeQueries) CpuUsage(ns NamespaceSelector, cluster string, stat Stat, window, subStep Duration) string {
+	usage := Select("container_cpu_usage_seconds_total",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+	return stat.Over(Subquery(
+		q.by(Rate(usage.Range(MustDuration("5m"))), ns, cluster, AvgBy),
+		window, subStep,
+	)).String()
+}
+
+// Data coverage, for confidence grading.
+
+// Coverage is the fraction of the window's subquery steps with working set
+// data, so a container that ran for a day of a 7d window covers ~0.14.
+func (q RightsizeQueries) Coverage(ns NamespaceSelector, cluster string, window, subStep Duration) string {
+	usage := Select("container_memory_working_set_bytes",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+	steps := Call("scalar", CountOverTime(Subquery(Call("vector", Num(1)), window, subStep)))
+	return Div(CountOverTime(Subquery(q.by(usage, ns, cluster, AvgBy), window, subStep)), steps).String()
+}
+
+// PodAge is the age in hours of the oldest running pod.
+func (q RightsizeQueries) PodAge(ns NamespaceSelector, cluster string) string {
+	info := Select("kube_pod_container_info", q.Labels.scope(ns, cluster)...)
+	start := Select("kube_pod_start_time", q.Labels.scope(ns, cluster)...)
+	age := Div(Sub(Call("time"), start), Num(3600))
+	perContainer := GroupLeft(On(Mul(info, age), "namespace", "pod", q.Labels.Cluster))
+	return q.by(perContainer, ns, cluster, MaxBy).String()
+}
+
+// Restarts is the most restarts of one pod over the window, including pods
+// replaced since.
+func (q RightsizeQueries) Restarts(ns NamespaceSelector, cluster string, window Duration) string {
+	sel := Select("kube_pod_container_status_restarts_total", q.Labels.scope(ns, cluster)...)
+	return q.byOver(Increase(sel.Range(window)), ns, cluster, window, MaxBy).String()
+}
+
+// Replica counts, keyed by LabelSchema.ReplicaLabels: now, averaged over
+// the window, and at peak.
+
+func (q RightsizeQueries) Replicas(ns NamespaceSelector, cluster string) string {
+	return q.replicas(ns, cluster).String()
+}
+
+func (q RightsizeQueries) ReplicasAvg(ns NamespaceSelector, cluster string, window, subStep Duration) string {
+	return AvgOverTime(Subquery(q.replicas(ns, cluster), window, subStep)).String()
+}
+
+func (q RightsizeQueries) ReplicasPeak(ns NamespaceSelector, cluster string, window, subStep Duration) string {
+	return MaxOverTime(Subquery(q.replicas(ns, cluster), window, subStep)).String()
+}
+
+// replicas reads Deployment, StatefulSet and DaemonSet replica counts (the
+// status already follows any HPA), counting pods for other owners (Jobs,
+// bare ReplicaSets) and when results are not keyed by workload.
+func (q RightsizeQueries) replicas(ns NamespaceSelector, cluster string) Expr {
+	info := Select("kube_pod_container_info", q.Labels.scope(ns, cluster)...)
+	if !q.Labels.Workloads {
+		return q.by(info, ns, cluster, countBy)
+	}
+
+	by := q.Labels.ReplicaLabels()
+	kind := func(metric, kind, label string) Expr {
+		sel := Select(metric, q.Labels.scope(ns, cluster)...)
+		return MaxBy(LabelReplace(
+			LabelReplace(sel, WorkloadLabel, "$1", label, "(.*)"),
+			WorkloadKindLabel, kind, label, ".*",
+		), by...)
+	}
+	pods := countBy(
+		MaxBy(q.owned(info, ns, cluster, ""), "namespace", "pod", q.Labels.Cluster, WorkloadKindLabel, WorkloadLabel),
+		by...,
+	)
+
+	return Or(Or(Or(
+		kind("kube_deployment_status_replicas", "Deployment", "deployment"),
+		kind("kube_statefulset_status_replicas", "StatefulSet", "statefulset")),
+		kind("kube_daemonset_status_current_number_scheduled", "DaemonSet", "daemonset")),
+		pods,
+	)
+}
+
+func countBy(e Expr, by ...string) Expr { return Aggregate("count", e, by...) }
+
+func (q RightsizeQueries) MemRequests(ns NamespaceSelector, cluster string) string {
+	return q.by(q.requests(ns, cluster, "memory"), ns, cluster, AvgBy).String()
+}
+
+func (q RightsizeQueries) CpuRequests(ns NamespaceSelector, cluster string) string {
+	return q.by(q.requests(ns, cluster, "cpu"), ns, cluster, AvgBy).String()
+}
+
+// Limits and peak usage, for limit recommendations.
+
+func (q RightsizeQueries) limits(ns NamespaceSelector, cluster, resource string) Expr {
+	scope := append(q.Labels.scope(ns, cluster), Eq("resource", resource))
+	return Or(
+		Select("kube_pod_container_resource_limits", scope...),
+		Select("kube_pod_init_container_resource_limits", scope...),
+	)
+}
+
+// MemLimits and CpuLimits cover regular and init containers; containers
+// without a limit have no series.
+func (q RightsizeQueries) MemLimits(ns NamespaceSelector, cluster string) string {
+	return q.by(q.limits(ns, cluster, "memory"), ns, cluster, AvgBy).String()
+}
+
+func (q RightsizeQueries) CpuLimits(ns NamespaceSelector, cluster string) string {
+	return q.by(q.limits(ns, cluster, "cpu"), ns, cluster, AvgBy).String()
+}
+
+// MemPeak is the max working set over the window (bytes).
+func (q RightsizeQueries) MemPeak(ns NamespaceSelector, cluster string, window Duration) string {
+	usage := Select("container_memory_working_set_bytes", q.Labels.scope(ns, cluster)...)
+	return q.byOver(MaxOverTime(usage.Range(window)), ns, cluster, window, MaxBy).String()
+}
+
+// CpuPeak is the p99.9 of CPU usage over the window (cores); the plain max
+// of a 5m rate is dominated by single scrape outliers.
+func (q RightsizeQueries) CpuPeak(ns NamespaceSelector, cluster string, window, subStep Duration) string {
+	usage := Select("container_cpu_usage_seconds_total",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+	peak := QuantileOverTime(0.999, Subquery(Rate(usage.Range(MustDuration("5m"))), window, subStep))
+	return q.byOver(peak, ns, cluster, window, MaxBy).String()
+}
+
+// Init containers: kube-state-metrics reports their requests separately and
+// they run briefly, so they are sized to peak usage rather than p95.
+
+func (q RightsizeQueries) initRequests(ns NamespaceSelector, cluster, resource string) Selector {
+	return Select("kube_pod_init_container_resource_requests",
+		append(q.Labels.scope(ns, cluster), Eq("resource", resource))...,
+	)
+}
+
+// onlyInit keeps per-container series of init containers seen in the window.
+func (q RightsizeQueries) onlyInit(e Expr, ns NamespaceSelector, cluster string, window Duration) Expr {
+	info := Select("kube_pod_init_container_info", q.Labels.scope(ns, cluster)...)
+	return On(And(e, MaxOverTime(info.Range(window))), "namespace", "pod", "container", q.Labels.Cluster)
+}
+
+func (q RightsizeQueries) InitMemRequests(ns NamespaceSelector, cluster string) string {
+	return q.by(q.initRequests(ns, cluster, "memory"), ns, cluster, AvgBy).String()
+}
+
+func (q RightsizeQueries) InitCpuRequests(ns NamespaceSelector, cluster string) string {
+	return q.by(q.initRequests(ns, cluster, "cpu"), ns, cluster, AvgBy).String()
+}
+
+func (q RightsizeQueries) InitMemPeak(ns NamespaceSelector, cluster string, window Duration) string {
+	usage := Select("container_memory_working_set_bytes", q.Labels.scope(ns, cluster)...)
+	return q.byOver(q.onlyInit(MaxOverTime(usage.Range(window)), ns, cluster, window), ns, cluster, window, MaxBy).String()
+}
+
+func (q RightsizeQueries) InitCpuPeak(ns NamespaceSelector, cluster string, window, subStep Duration) string {
+	usage := Select("container_cpu_usage_seconds_total",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+	peak := MaxOverTime(Subquery(Rate(usage.Range(MustDuration("5m"))), window, subStep))
+	return q.byOver(q.onlyInit(peak, ns, cluster, window), ns, cluster, window, MaxBy).String()
 }
 
 // OOMKilled guardrail (best-effort; depends on kube-state-metrics availability)
-func OOMKilled(namespace, cluster, window string) string {
-	return fmt.Sprintf(`
-max by (namespace, container, uw_cluster) (
-  max_over_time(
-    kube_pod_container_status_last_terminated_reason{
-      namespace="%s",
-      uw_cluster="%s",
-      reason="OOMKilled"
-    }[%s]
-  )
-)
-`, namespace, cluster, window)
-}
-
-func CPUThrottling(namespace, cluster, window string) string {
-	return fmt.Sprintf(`
-max by (namespace, container, uw_cluster) (
-  increase(
-    container_cpu_cfs_throttled_seconds_total{
-      namespace="%s",
-      uw_cluster="%s",
-      container!="POD",
-      container!=""
-    }[%s]
-  )
-)
-`, namespace, cluster, window)
-}
-
-func JVMHeapAfterGC(namespace, cluster string) string {
-	return fmt.Sprintf(`
-avg by (namespace, container, uw_cluster) (
-  jvm_memory_usage_after_gc{
-    namespace="%s",
-    uw_cluster="%s",
-    area="heap"
-  }
-)
-`, namespace, cluster)
-}
-
-func JVMNonHeapBytes(namespace, cluster string) string {
-	return fmt.Sprintf(`
-avg by (namespace, container, uw_cluster) (
-  jvm_memory_used_bytes{
-    namespace="%s",
-    uw_cluster="%s",
-    area!="heap"
-  }
-)
-`, namespace, cluster)
+func (q RightsizeQueries) OOMKilled(ns NamespaceSelector, cluster string, window Duration) string {
+	sel := Select("kube_pod_container_status_last_terminated_reason",
+		append(q.Labels.scope(ns, cluster), Eq("reason", "OOMKilled"))...,
+	)
+	return q.byOver(MaxOverTime(sel.Range(window)), ns, cluster, window, MaxBy).String()
+}
+
+func (q RightsizeQueries) CPUThrottling(ns NamespaceSelector, cluster string, window Duration) string {
+	sel := Select("container_cpu_cfs_throttled_seconds_total",
+		append(q.Labels.scope(ns, cluster), realContainers()...)...,
+	)
+	return q.byOver(Increase(sel.Range(window)), ns, cluster, window, MaxBy).String()
+}
+
+func (q RightsizeQueries) JVMHeapAfterGC(ns NamespaceSelector, cluster string) string {
+	sel := Select("jvm_memory_usage_after_gc",
+		append(q.Labels.scope(ns, cluster), Eq("area", "heap"))...,
+	)
+	return q.by(sel, ns, cluster, AvgBy).String()
+}
+
+func (q RightsizeQueries) JVMNonHeapBytes(ns NamespaceSelector, cluster string) string {
+	sel := Select("jvm_memory_used_bytes",
+		append(q.Labels.scope(ns, cluster), Neq("area", "heap"))...,
+	)
+	return q.by(sel, ns, cluster, AvgBy).String()
 }
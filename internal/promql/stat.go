@@ -0,0 +1,47 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Stat is a usage statistic over the window: a quantile (p50, p95, p99.9)
+// or the maximum.
+type Stat string
+
+const (
+	StatMax Stat = "max"
+	StatP95 Stat = "p95"
+)
+
+var statRE = regexp.MustCompile(`^p([0-9]{1,2}(\.[0-9]+)?)$`)
+
+func ParseStat(s string) (Stat, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == string(StatMax) {
+		return StatMax, nil
+	}
+	m := statRE.FindStringSubmatch(s)
+	if m == nil {
+		return "", fmt.Errorf("invalid statistic %q (want e.g. p50, p95, p99.9, max)", s)
+	}
+	if q, _ := strconv.ParseFloat(m[1], 64); q <= 0 {
+		return "", fmt.Errorf("invalid statistic %q (quantile must be above 0)", s)
+	}
+	return Stat(s), nil
+}
+
+// Over applies the statistic to a range vector: quantile_over_time or max_over_time.
+func (s Stat) Over(r Expr) Expr {
+	if s == StatMax {
+		return MaxOverTime(r)
+	}
+	// Parse the percentile scaled by 1e-2 so p99.9 is exactly 0.999 (99.9/100 is not)
+	q, err := strconv.ParseFloat(strings.TrimPrefix(string(s), "p")+"e-2", 64)
+	if err != nil {
+		panic(fmt.Sprintf("promql: invalid statistic %q", s))
+	}
+	return QuantileOverTime(q, r)
+}
@@ -0,0 +1,51 @@
+package promql
+
+import "testing"
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Stat
+		wantErr bool
+	}{
+		{in: "p95", want: StatP95},
+		{in: " P50 ", want: "p50"},
+		{in: "p99.9", want: "p99.9"},
+		{in: "max", want: StatMax},
+		{in: "MAX", want: StatMax},
+		{in: "p0", wantErr: true},
+		{in: "p100", wantErr: true},
+		{in: "p", wantErr: true},
+		{in: "95", wantErr: true},
+		{in: "avg", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseStat(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStat(%q) = %q, %v; want error %v", tt.in, got, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseStat(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatOver(t *testing.T) {
+	r := Select("up").Range(MustDuration("5m"))
+	tests := map[Stat]string{
+		StatMax: "max_over_time(up{}[5m])",
+		StatP95: "quantile_over_time(0.95, up{}[5m])",
+		"p99.9": "quantile_over_time(0.999, up{}[5m])",
+		"p50":   "quantile_over_time(0.5, up{}[5m])",
+	}
+
+	for st, want := range tests {
+		if got := st.Over(r).String(); got != want {
+			t.Errorf("%s.Over = %s, want %s", st, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package promql
+
+// podOwners maps every pod to its owning workload: one series per
+// (namespace, pod, cluster) with workload and workload_kind labels.
+// Pods owned by a ReplicaSet are resolved one level further to the
+// ReplicaSet's owner (usually a Deployment); other owners (StatefulSet,
+// DaemonSet, Job, ...) and ReplicaSets without an owner are used as-is.
+// A non-empty window reads ownership over that window rather than now, so
+// pods replaced inside it still resolve.
+func (q RightsizeQueries) podOwners(ns NamespaceSelector, cluster string, window Duration) Expr {
+	scope := q.Labels.scope(ns, cluster)
+	podKey := []string{"namespace", "pod", q.Labels.Cluster}
+	over := func(s Selector) Expr {
+		if window == "" {
+			return s
+		}
+		return MaxOverTime(s.Range(window))
+	}
+
+	viaReplicaSet := GroupLeft(On(Mul(
+		LabelReplace(
+			over(Select("kube_pod_owner", scope...).With(Eq("owner_kind", "ReplicaSet"))),
+			"replicaset", "$1", "owner_name", "(.*)",
+		),
+		MaxBy(
+			over(Select("kube_replicaset_owner", scope...)),
+			"namespace", "replicaset", q.Labels.Cluster, "owner_kind", "owner_name",
+		),
+	), "namespace", "replicaset", q.Labels.Cluster), "owner_kind", "owner_name")
+
+	direct := over(Select("kube_pod_owner", scope...).With(Neq("owner_kind", "<none>")))
+
+	return MaxBy(
+		ownerLabels(On(Or(viaReplicaSet, direct), podKey...)),
+		append(podKey, WorkloadKindLabel, WorkloadLabel)...,
+	)
+}
+
+func ownerLabels(e Expr) Expr {
+	return LabelReplace(
+		LabelReplace(e, WorkloadLabel, "$1", "owner_name", "(.*)"),
+		WorkloadKindLabel, "$1", "owner_kind", "(.*)",
+	)
+}
+
+// owned attaches workload labels to per-pod series when the schema keys
+// results by workload; otherwise it returns e unchanged. Ownership is read
+// over window ("" = now; see podOwners). Pods without an owner (bare pods)
+// are their own workload of kind Pod.
+func (q RightsizeQueries) owned(e Expr, ns NamespaceSelector, cluster string, window Duration) Expr {
+	if !q.Labels.Workloads {
+		return e
+	}
+	podKey := []string{"namespace", "pod", q.Labels.Cluster}
+	joined := GroupLeft(
+		On(Mul(e, q.podOwners(ns, cluster, window)), podKey...),
+		WorkloadKindLabel, WorkloadLabel,
+	)
+	bare := LabelReplace(
+		LabelReplace(e, WorkloadLabel, "$1", "pod", "(.*)"),
+		WorkloadKindLabel, "Pod", "pod", ".*",
+	)
+	return On(Or(joined, bare), podKey...)
+}
@@ -0,0 +1,73 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// Evidence is the data a recommendation rests on; nil values are unknown
+// (their signal could not be fetched). Unknown pod age and restarts are not
+// graded; unknown coverage caps the grade at medium.
+type Evidence struct {
+	Coverage    *float64 // fraction of the window with usage data
+	PodAgeHours *float64 // oldest running pod
+	Restarts    *float64 // most restarts of one pod in the window
+}
+
+// GradeConfidence is low when any factor misses its bar, medium when
+// coverage is short of high or unknown or the container restarted at all,
+// and high otherwise. The explanation lists the factors that lowered it.
+func GradeConfidence(e Evidence, b ConfidenceBars) (model.Confidence, string) {
+	var low, medium []string
+
+	if e.Coverage == nil {
+		medium = append(medium, "no coverage data")
+	} else {
+		switch c := *e.Coverage; {
+		case c < b.MinCoverage:
+			low = append(low, fmt.Sprintf("coverage %.0f%% < %.0f%%", c*100, b.MinCoverage*100))
+		case c < b.HighCoverage:
+			medium = append(medium, fmt.Sprintf("coverage %.0f%%", c*100))
+		}
+	}
+	if e.PodAgeHours != nil && *e.PodAgeHours < b.MinPodAgeHours {
+		low = append(low, fmt.Sprintf("oldest pod %.1fh < %.0fh", *e.PodAgeHours, b.MinPodAgeHours))
+	}
+	if e.Restarts != nil {
+		// increase() extrapolates, so counts come back fractional
+		switch n := math.Round(*e.Restarts); {
+		case n > b.MaxRestarts:
+			low = append(low, fmt.Sprintf("%.0f restarts > %.0f", n, b.MaxRestarts))
+		case n > 0:
+			medium = append(medium, fmt.Sprintf("%.0f restarts", n))
+		}
+	}
+
+	switch {
+	case len(low) > 0:
+		return model.ConfidenceLow, strings.Join(low, ", ")
+	case len(medium) > 0:
+		return model.ConfidenceMedium, strings.Join(medium, ", ")
+	}
+	return model.ConfidenceHigh, ""
+}
+
+// KeepLowConfidence downgrades REDUCE and INCREASE to KEEP at the current
+// request, noting why on the original explanation.
+func KeepLowConfidence(r *model.RightsizeResult) {
+	if r.Confidence != model.ConfidenceLow {
+		return
+	}
+	note := "; low confidence (" + r.ConfidenceWhy + "), keeping"
+	if r.MemoryDecision == model.MemReduce || r.MemoryDecision == model.MemIncrease {
+		r.MemoryDecision, r.MemoryWhy = model.MemKeep, r.MemoryWhy+note
+		r.MemRecommendedBytes, r.MemDeltaBytes = r.MemRequestBytes, 0
+	}
+	if r.CPUDecision == model.CPUReduce || r.CPUDecision == model.CPUIncrease {
+		r.CPUDecision, r.CPUWhy = model.CPUKeep, r.CPUWhy+note
+		r.CpuRecommendedCores, r.CpuDeltaCores = r.CpuRequestCores, 0
+	}
+}
@@ -0,0 +1,71 @@
+package decision
+
+import (
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestGradeConfidence(t *testing.T) {
+	bars := DefaultThresholds.Confidence
+
+	tests := []struct {
+		name     string
+		evidence Evidence
+		want     model.Confidence
+		why      string
+	}{
+		{name: "all good", evidence: Evidence{Coverage: ptr(0.95), PodAgeHours: ptr(240), Restarts: ptr(0)}, want: model.ConfidenceHigh},
+		{name: "unknown coverage", evidence: Evidence{PodAgeHours: ptr(240), Restarts: ptr(0)}, want: model.ConfidenceMedium, why: "no coverage data"},
+		{name: "nothing known", want: model.ConfidenceMedium, why: "no coverage data"},
+		{name: "short coverage", evidence: Evidence{Coverage: ptr(0.7)}, want: model.ConfidenceMedium, why: "coverage 70%"},
+		{name: "coverage below minimum", evidence: Evidence{Coverage: ptr(0.3)}, want: model.ConfidenceLow, why: "coverage 30% < 50%"},
+		{name: "young pods", evidence: Evidence{Coverage: ptr(1), PodAgeHours: ptr(6)}, want: model.ConfidenceLow, why: "oldest pod 6.0h < 24h"},
+		{name: "some restarts", evidence: Evidence{Coverage: ptr(1), Restarts: ptr(2)}, want: model.ConfidenceMedium, why: "2 restarts"},
+		{name: "many restarts", evidence: Evidence{Coverage: ptr(1), Restarts: ptr(9)}, want: model.ConfidenceLow, why: "9 restarts > 5"},
+		// increase() extrapolates: 0.4 restarts is none, 5.4 is within the bar
+		{name: "fractional restarts round down", evidence: Evidence{Coverage: ptr(1), Restarts: ptr(0.4)}, want: model.ConfidenceHigh},
+		{name: "fractional restarts at the bar", evidence: Evidence{Coverage: ptr(1), Restarts: ptr(5.4)}, want: model.ConfidenceMedium, why: "5 restarts"},
+		{name: "low reasons only", evidence: Evidence{Coverage: ptr(0.3), PodAgeHours: ptr(1), Restarts: ptr(1)}, want: model.ConfidenceLow, why: "coverage 30% < 50%, oldest pod 1.0h < 24h"},
+		{name: "medium reasons joined", evidence: Evidence{Coverage: ptr(0.6), Restarts: ptr(1)}, want: model.ConfidenceMedium, why: "coverage 60%, 1 restarts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, why := GradeConfidence(tt.evidence, bars)
+			if got != tt.want || why != tt.why {
+				t.Errorf("GradeConfidence = %s %q, want %s %q", got, why, tt.want, tt.why)
+			}
+		})
+	}
+}
+
+func TestKeepLowConfidence(t *testing.T) {
+	r := model.RightsizeResult{
+		MemRequestBytes: 1 << 30, MemRecommendedBytes: 1 << 29, MemDeltaBytes: -(1 << 29),
+		CpuRequestCores: 1, CpuRecommendedCores: 1.5, CpuDeltaCores: 0.5,
+		MemoryDecision: model.MemReduce, MemoryWhy: "mem low",
+		CPUDecision: model.CPUIncrease, CPUWhy: "cpu high",
+		Confidence: model.ConfidenceLow, ConfidenceWhy: "coverage 30% < 50%",
+	}
+
+	medium := r
+	medium.Confidence = model.ConfidenceMedium
+	KeepLowConfidence(&medium)
+	if medium.MemoryDecision != model.MemReduce || medium.CPUDecision != model.CPUIncrease {
+		t.Errorf("medium confidence changed decisions: %s/%s", medium.MemoryDecision, medium.CPUDecision)
+	}
+
+	KeepLowConfidence(&r)
+	if r.MemoryDecision != model.MemKeep || r.MemRecommendedBytes != r.MemRequestBytes || r.MemDeltaBytes != 0 {
+		t.Errorf("memory = %s %d (delta %d), want KEEP at the request", r.MemoryDecision, r.MemRecommendedBytes, r.MemDeltaBytes)
+	}
+	if r.CPUDecision != model.CPUKeep || r.CpuRecommendedCores != r.CpuRequestCores || r.CpuDeltaCores != 0 {
+		t.Errorf("cpu = %s %v (delta %v), want KEEP at the request", r.CPUDecision, r.CpuRecommendedCores, r.CpuDeltaCores)
+	}
+	if want := "mem low; low confidence (coverage 30% < 50%), keeping"; r.MemoryWhy != want {
+		t.Errorf("memory why = %q, want %q", r.MemoryWhy, want)
+	}
+}
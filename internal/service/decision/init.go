@@ -0,0 +1,38 @@
+package decision
+
+import (
+	"fmt"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// Init containers run briefly before the app starts, so their p95 is
+// meaningless; they are judged on peak usage/request instead, and CPU
+// throttling (which only slows startup) never blocks a change.
+
+func DecideInitMemory(peakRatio float64, oomKilled bool, b Band) (model.MemoryDecision, string) {
+	if oomKilled {
+		return model.MemSkipOOM, "OOMKilled detected in lookback window"
+	}
+	if peakRatio <= 0 {
+		return model.MemKeep, "no init memory peak data (keeping)"
+	}
+	if peakRatio > b.Increase {
+		return model.MemIncrease, fmt.Sprintf("init mem peak ratio %.2f > %.2f (risk)", peakRatio, b.Increase)
+	}
+	if peakRatio < b.Reduce {
+		return model.MemReduce, fmt.Sprintf("init mem peak ratio %.2f < %.2f (overprovisioned)", peakRatio, b.Reduce)
+	}
+	return model.MemKeep, fmt.Sprintf("init mem peak ratio %.2f within healthy band", peakRatio)
+}
+
+func DecideInitCPU(peakRatio float64, b Band) (model.CPUDecision, string) {
+	if peakRatio <= 0 {
+		return model.CPUKeep, "no init cpu peak data (keeping)"
+	}
+	if peakRatio < b.Reduce {
+		return model.CPUReduce, fmt.Sprintf("init cpu peak ratio %.2f < %.2f (overprovisioned)", peakRatio, b.Reduce)
+	}
+	// Above-request bursts only slow startup; not worth more requests
+	return model.CPUKeep, fmt.Sprintf("init cpu peak ratio %.2f (startup only, keeping)", peakRatio)
+}
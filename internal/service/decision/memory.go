@@ -6,18 +6,18 @@ import (
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
 )
 
-func DecideMemory(p95Ratio float64, oomKilled bool) (model.MemoryDecision, string) {
+func DecideMemory(stat string, ratio float64, oomKilled bool, b Band) (model.MemoryDecision, string) {
 	if oomKilled {
 		return model.MemSkipOOM, "OOMKilled detected in lookback window"
 	}
-	if p95Ratio <= 0 {
+	if ratio <= 0 {
 		return model.MemKeep, "no memory ratio data (keeping)"
 	}
-	if p95Ratio > 0.90 {
-		return model.MemIncrease, fmt.Sprintf("mem p95 ratio %.2f > 0.90 (risk)", p95Ratio)
+	if ratio > b.Increase {
+		return model.MemIncrease, fmt.Sprintf("mem %s ratio %.2f > %.2f (risk)", stat, ratio, b.Increase)
 	}
-	if p95Ratio < 0.60 {
-		return model.MemReduce, fmt.Sprintf("mem p95 ratio %.2f < 0.60 (overprovisioned)", p95Ratio)
+	if ratio < b.Reduce {
+		return model.MemReduce, fmt.Sprintf("mem %s ratio %.2f < %.2f (overprovisioned)", stat, ratio, b.Reduce)
 	}
-	return model.MemKeep, fmt.Sprintf("mem p95 ratio %.2f within healthy band", p95Ratio)
+	return model.MemKeep, fmt.Sprintf("mem %s ratio %.2f within healthy band", stat, ratio)
 }
@@ -0,0 +1,37 @@
+package decision
+
+// Band splits a usage/request ratio into REDUCE (below Reduce), KEEP, and
+// INCREASE (above Increase).
+type Band struct {
+	Reduce   float64
+	Increase float64
+}
+
+// Thresholds are the decision bands for one container.
+type Thresholds struct {
+	CPU    Band
+	Memory Band
+
+	// JVM: after-GC heap ratio and non-heap/memory-request ratio above which to INCREASE
+	JVMHeapAfterGC  float64
+	JVMNonHeapRatio float64
+
+	Confidence ConfidenceBars
+}
+
+// ConfidenceBars grade the data behind a recommendation (see GradeConfidence).
+type ConfidenceBars struct {
+	MinCoverage    float64 // less of the window with usage data: low
+	HighCoverage   float64 // less: at most medium
+	MinPodAgeHours float64 // younger oldest pod: low
+	MaxRestarts    float64 // more restarts of one pod: low; any: at most medium
+}
+
+// DefaultThresholds is the built-in policy.
+var DefaultThresholds = Thresholds{
+	CPU:             Band{Reduce: 0.60, Increase: 0.90},
+	Memory:          Band{Reduce: 0.60, Increase: 0.90},
+	JVMHeapAfterGC:  0.80,
+	JVMNonHeapRatio: 0.30,
+	Confidence:      ConfidenceBars{MinCoverage: 0.50, HighCoverage: 0.90, MinPodAgeHours: 24, MaxRestarts: 5},
+}
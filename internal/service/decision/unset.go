@@ -0,0 +1,18 @@
+package decision
+
+import (
+	"fmt"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// Containers without a request are only sized in absolute mode, from usage
+// alone; setting a request is always an increase.
+
+func DecideUnsetMemory(stat string, usageBytes int64) (model.MemoryDecision, string) {
+	return model.MemIncrease, fmt.Sprintf("no memory request set (mem %s usage %dMi)", stat, usageBytes>>20)
+}
+
+func DecideUnsetCPU(stat string, usageCores float64) (model.CPUDecision, string) {
+	return model.CPUIncrease, fmt.Sprintf("no cpu request set (cpu %s usage %.3f cores)", stat, usageCores)
+}
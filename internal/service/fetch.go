@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+)
+
+const (
+	defaultConcurrency  = 4
+	defaultQueryTimeout = 30 * time.Second
+)
+
+// signal is one PromQL query feeding the rightsize computation.
+// Required signals abort the run on failure; optional ones are recorded
+// in meta and treated as empty.
+type signal struct {
+	name     string
+	expr     string
+	optional bool
+}
+
+type signalResult struct {
+	samples  []vm.Sample
+	warnings []string
+	err      error
+}
+
+// fetchReport is what fetchSignals learned besides the samples themselves.
+type fetchReport struct {
+	failed   []model.SignalError
+	warnings []string
+}
+
+// fetchSignals runs all signals with at most cap(sem) queries in flight,
+// each bounded by `timeout`. The semaphore may be shared across calls
+// (one per cluster). It returns samples keyed by signal name.
+func (s *RightsizeService) fetchSignals(
+	ctx context.Context,
+	signals []signal,
+	sem chan struct{},
+	timeout time.Duration,
+) (map[string][]vm.Sample, fetchReport, error) {
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]signalResult, len(signals))
+	var wg sync.WaitGroup
+
+	// The first required failure wins; later ones are usually just our cancellation.
+	var fatalOnce sync.Once
+	var fatal error
+
+	for i, sig := range signals {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				if !sig.optional {
+					fatalOnce.Do(func() { fatal = fmt.Errorf("%s: %w", sig.name, ctx.Err()) })
+				}
+				return
+			}
+
+			qctx, qcancel := context.WithTimeout(ctx, timeout)
+			defer qcancel()
+
+			samples, warnings, err := s.query(qctx, sig.expr)
+			results[i] = signalResult{samples: samples, warnings: warnings, err: err}
+
+			// A required signal failing dooms the run; stop the rest early.
+			if err != nil && !sig.optional {
+				fatalOnce.Do(func() {
+					fatal = fmt.Errorf("%s: %w", sig.name, err)
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	var report fetchReport
+	if fatal != nil {
+		return nil, report, fatal
+	}
+
+	out := make(map[string][]vm.Sample, len(signals))
+	for i, sig := range signals {
+		if err := results[i].err; err != nil {
+			report.failed = append(report.failed, model.SignalError{Signal: sig.name, Error: err.Error()})
+			continue
+		}
+		for _, w := range results[i].warnings {
+			report.warnings = append(report.warnings, sig.name+": "+w)
+		}
+		out[sig.name] = results[i].samples
+	}
+
+	return out, report, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/promql"
+)
+
+// Filter trims results before ranking, so every output shows the same rows.
+// A service passing in any cluster is kept in all of them. The zero value
+// keeps everything.
+type Filter struct {
+	// Only keeps rows whose memory or CPU decision is listed; entries
+	// prefixed with "!" drop rows where either decision is listed instead.
+	// SKIP stands for every SKIP_* decision.
+	Only []string
+
+	// Container name patterns (RE2, full match)
+	ContainerInclude string
+	ContainerExclude string
+
+	// Minimum absolute changes (0 = off); a row is kept when any enabled
+	// minimum is reached
+	MinMemDeltaMiB int64
+	MinCPUDeltam   int64
+
+	// Missing: keep, hide or only show rows without usage data ("" = keep)
+	Missing string
+}
+
+// Missing-data filter modes (Filter.Missing).
+const (
+	MissingKeep = "keep"
+	MissingHide = "hide"
+	MissingOnly = "only"
+)
+
+var filterDecisions = []string{"REDUCE", "KEEP", "INCREASE", "SKIP", "SKIP_OOM", "SKIP_THROTTLING"}
+
+// resultFilter is a validated Filter.
+type resultFilter struct {
+	only, except     []string
+	include, exclude *regexp.Regexp
+	minMem           int64
+	minCPU           float64
+	missing          string
+	desc             []string // for RightsizeMeta.Filters
+}
+
+func compileFilter(f Filter) (*resultFilter, error) {
+	rf := &resultFilter{
+		minMem:  f.MinMemDeltaMiB * 1024 * 1024,
+		minCPU:  float64(f.MinCPUDeltam) / 1000,
+		missing: f.Missing,
+	}
+
+	for _, d := range f.Only {
+		d = strings.ToUpper(strings.TrimSpace(d))
+		name, negated := strings.CutPrefix(d, "!")
+		if !slices.Contains(filterDecisions, name) {
+			return nil, fmt.Errorf("only: unknown decision %q (want %s, optionally prefixed with !)", name, strings.Join(filterDecisions, "|"))
+		}
+		if negated {
+			rf.except = append(rf.except, name)
+		} else {
+			rf.only = append(rf.only, name)
+		}
+		rf.desc = append(rf.desc, "only="+d)
+	}
+
+	var err error
+	if rf.include, err = compileContainer(f.ContainerInclude); err != nil {
+		return nil, fmt.Errorf("container include: %w", err)
+	}
+	if rf.exclude, err = compileContainer(f.ContainerExclude); err != nil {
+		return nil, fmt.Errorf("container exclude: %w", err)
+	}
+	if f.ContainerInclude != "" {
+		rf.desc = append(rf.desc, "container=~"+f.ContainerInclude)
+	}
+	if f.ContainerExclude != "" {
+		rf.desc = append(rf.desc, "container!~"+f.ContainerExclude)
+	}
+
+	if f.MinMemDeltaMiB < 0 || f.MinCPUDeltam < 0 {
+		return nil, fmt.Errorf("minimum deltas must not be negative")
+	}
+	if f.MinMemDeltaMiB > 0 {
+		rf.desc = append(rf.desc, fmt.Sprintf("min-mem-delta=%dMi", f.MinMemDeltaMiB))
+	}
+	if f.MinCPUDeltam > 0 {
+		rf.desc = append(rf.desc, fmt.Sprintf("min-cpu-delta=%dm", f.MinCPUDeltam))
+	}
+
+	switch rf.missing {
+	case "", MissingKeep:
+		rf.missing = MissingKeep
+	case MissingHide, MissingOnly:
+		rf.desc = append(rf.desc, "missing="+rf.missing)
+	default:
+		return nil, fmt.Errorf("unknown missing-data filter %q (want %s|%s|%s)", rf.missing, MissingKeep, MissingHide, MissingOnly)
+	}
+
+	return rf, nil
+}
+
+func compileContainer(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if err := promql.ValidateRegexp(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.MustCompile("^(?:" + pattern + ")$"), nil
+}
+
+// apply returns the kept rows and how many were dropped. Like TopK it works
+// on services, not rows: a service with a row kept in one cluster keeps
+// its rows in every cluster so the cross-cluster merge stays complete.
+func (rf *resultFilter) apply(results []model.RightsizeResult) ([]model.RightsizeResult, int) {
+	kept := map[string]bool{}
+	for _, r := range results {
+		if rf.keep(r) {
+			kept[serviceKey(r)] = true
+		}
+	}
+	out := results[:0:0]
+	for _, r := range results {
+		if kept[serviceKey(r)] {
+			out = append(out, r)
+		}
+	}
+	return out, len(results) - len(out)
+}
+
+func (rf *resultFilter) keep(r model.RightsizeResult) bool {
+	matches := func(list []string) bool {
+		for _, d := range list {
+			for _, got := range []string{string(r.MemoryDecision), string(r.CPUDecision)} {
+				if d == got || d == decisionClass(got) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if len(rf.only) > 0 && !matches(rf.only) {
+		return false
+	}
+	if matches(rf.except) {
+		return false
+	}
+
+	if rf.include != nil && !rf.include.MatchString(r.Container) {
+		return false
+	}
+	if rf.exclude != nil && rf.exclude.MatchString(r.Container) {
+		return false
+	}
+
+	if rf.minMem > 0 || rf.minCPU > 0 {
+		memOK := rf.minMem > 0 && math.Abs(float64(r.MemDeltaBytes)) >= float64(rf.minMem)
+		cpuOK := rf.minCPU > 0 && math.Abs(r.CpuDeltaCores) >= rf.minCPU-1e-9
+		if !memOK && !cpuOK {
+			return false
+		}
+	}
+
+	switch rf.missing {
+	case MissingHide:
+		return !missingData(r)
+	case MissingOnly:
+		return missingData(r)
+	}
+	return true
+}
+
+// decisionClass folds every SKIP_* decision into SKIP.
+func decisionClass(d string) string {
+	if strings.HasPrefix(d, "SKIP") {
+		return "SKIP"
+	}
+	return d
+}
+
+// missingData reports rows without usage data for memory or CPU: no ratio,
+// and no absolute usage either.
+func missingData(r model.RightsizeResult) bool {
+	return (r.MemUsageRatio <= 0 && r.MemUsageBytes <= 0) ||
+		(r.CpuUsageRatio <= 0 && r.CpuUsageCores <= 0)
+}
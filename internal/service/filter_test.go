@@ -0,0 +1,107 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+func filterRow(cluster, container string, mem model.MemoryDecision, cpu model.CPUDecision) model.RightsizeResult {
+	return model.RightsizeResult{
+		Namespace: testNamespace, Cluster: cluster, Container: container,
+		MemUsageRatio: 0.5, CpuUsageRatio: 0.5,
+		MemoryDecision: mem, CPUDecision: cpu,
+	}
+}
+
+func TestFilter(t *testing.T) {
+	reduce := filterRow("c1", "api", model.MemReduce, model.CPUKeep)
+	reduce.MemDeltaBytes, reduce.CpuDeltaCores = -200*mib, -0.05
+	keep := filterRow("c1", "worker", model.MemKeep, model.CPUKeep)
+	oom := filterRow("c1", "cron", model.MemSkipOOM, model.CPUKeep)
+	throttled := filterRow("c1", "istio-proxy", model.MemKeep, model.CPUSkipThrottling)
+	throttled.CpuDeltaCores = 0.1
+	nodata := filterRow("c1", "batch", model.MemKeep, model.CPUKeep)
+	nodata.MemUsageRatio = 0
+	rows := []model.RightsizeResult{reduce, keep, oom, throttled, nodata}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{name: "zero value keeps all", want: "api worker cron istio-proxy batch"},
+		{name: "only reduce", filter: Filter{Only: []string{"reduce"}}, want: "api"},
+		{name: "SKIP covers every skip", filter: Filter{Only: []string{"SKIP"}}, want: "cron istio-proxy"},
+		{name: "exact skip", filter: Filter{Only: []string{"SKIP_OOM"}}, want: "cron"},
+		{name: "except skip", filter: Filter{Only: []string{"!SKIP"}}, want: "api worker batch"},
+		{name: "only and except", filter: Filter{Only: []string{"KEEP", "!REDUCE", "!SKIP"}}, want: "worker batch"},
+		{name: "container include", filter: Filter{ContainerInclude: "api|cron"}, want: "api cron"},
+		{name: "include is anchored", filter: Filter{ContainerInclude: "pro"}, want: ""},
+		{name: "container exclude", filter: Filter{ContainerExclude: "istio-.*"}, want: "api worker cron batch"},
+		{name: "min mem delta", filter: Filter{MinMemDeltaMiB: 100}, want: "api"},
+		{name: "min cpu delta either way", filter: Filter{MinCPUDeltam: 50}, want: "api istio-proxy"},
+		{name: "either minimum", filter: Filter{MinMemDeltaMiB: 500, MinCPUDeltam: 100}, want: "istio-proxy"},
+		{name: "hide missing", filter: Filter{Missing: MissingHide}, want: "api worker cron istio-proxy"},
+		{name: "only missing", filter: Filter{Missing: MissingOnly}, want: "batch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rf, err := compileFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, dropped := rf.apply(rows)
+			var names []string
+			for _, r := range out {
+				names = append(names, r.Container)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("kept %q, want %q", got, tt.want)
+			}
+			if dropped != len(rows)-len(out) {
+				t.Errorf("dropped = %d, want %d", dropped, len(rows)-len(out))
+			}
+		})
+	}
+}
+
+func TestFilterKeepsServiceInEveryCluster(t *testing.T) {
+	rows := []model.RightsizeResult{
+		filterRow("c1", "api", model.MemReduce, model.CPUKeep),
+		filterRow("c2", "api", model.MemKeep, model.CPUKeep),
+		filterRow("c2", "worker", model.MemKeep, model.CPUKeep),
+	}
+	rf, err := compileFilter(Filter{Only: []string{"REDUCE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, dropped := rf.apply(rows)
+	if len(out) != 2 || out[0].Cluster != "c1" || out[1].Cluster != "c2" || out[1].Container != "api" {
+		t.Errorf("kept %+v, want api in both clusters", out)
+	}
+	if dropped != 1 {
+		t.Errorf("dropped = %d, want 1", dropped)
+	}
+}
+
+func TestCompileFilterRejects(t *testing.T) {
+	tests := map[string]Filter{
+		"unknown decision": {Only: []string{"DROP"}},
+		"bad include":      {ContainerInclude: "("},
+		"bad exclude":      {ContainerExclude: "[a-"},
+		"negative minimum": {MinMemDeltaMiB: -1},
+		"unknown missing":  {Missing: "show"},
+	}
+
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := compileFilter(f); err == nil {
+				t.Errorf("compileFilter(%+v) succeeded, want an error", f)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	"math"
+)
+
+// Guardrails bound recommendations so one run never moves a request too
+// far. The zero value changes nothing.
+type Guardrails struct {
+	// Largest change per run, in percent of the current request (0 = no limit)
+	MaxMemReducePct   float64
+	MaxMemIncreasePct float64
+	MaxCPUReducePct   float64
+	MaxCPUIncreasePct float64
+
+	// Absolute bounds on recommended requests and limits (0 = none)
+	MemFloorMiB   int64
+	MemCeilingMiB int64
+	CPUFloorm     int64
+	CPUCeilingm   int64
+}
+
+// bounds are one resource's guardrails, in bytes or cores.
+type bounds struct {
+	maxReduce, maxIncrease float64 // percent
+	floor, ceiling         float64
+}
+
+// resultGuardrails is a validated Guardrails.
+type resultGuardrails struct {
+	mem, cpu bounds
+	desc     []string // for RightsizeMeta.Guardrails
+}
+
+func compileGuardrails(g Guardrails) (*resultGuardrails, error) {
+	rg := &resultGuardrails{
+		mem: bounds{
+			maxReduce:   g.MaxMemReducePct,
+			maxIncrease: g.MaxMemIncreasePct,
+			floor:       float64(g.MemFloorMiB) * 1024 * 1024,
+			ceiling:     float64(g.MemCeilingMiB) * 1024 * 1024,
+		},
+		cpu: bounds{
+			maxReduce:   g.MaxCPUReducePct,
+			maxIncrease: g.MaxCPUIncreasePct,
+			floor:       float64(g.CPUFloorm) / 1000,
+			ceiling:     float64(g.CPUCeilingm) / 1000,
+		},
+	}
+
+	for _, c := range []struct {
+		name string
+		b    bounds
+		unit func(float64) string
+	}{
+		{"mem", rg.mem, mebibytes},
+		{"cpu", rg.cpu, millicores},
+	} {
+		b := c.b
+		if b.maxReduce < 0 || b.maxReduce >= 100 {
+			return nil, fmt.Errorf("max %s reduction %g%% must be in [0, 100)", c.name, b.maxReduce)
+		}
+		if b.maxIncrease < 0 {
+			return nil, fmt.Errorf("max %s increase %g%% must not be negative", c.name, b.maxIncrease)
+		}
+		if b.floor < 0 || b.ceiling < 0 {
+			return nil, fmt.Errorf("%s floor and ceiling must not be negative", c.name)
+		}
+		if b.ceiling > 0 && b.ceiling < b.floor {
+			return nil, fmt.Errorf("%s ceiling %s is below floor %s", c.name, c.unit(b.ceiling), c.unit(b.floor))
+		}
+
+		if b.maxReduce > 0 {
+			rg.desc = append(rg.desc, fmt.Sprintf("%s -%g%%", c.name, b.maxReduce))
+		}
+		if b.maxIncrease > 0 {
+			rg.desc = append(rg.desc, fmt.Sprintf("%s +%g%%", c.name, b.maxIncrease))
+		}
+		if b.floor > 0 {
+			rg.desc = append(rg.desc, fmt.Sprintf("%s >= %s", c.name, c.unit(b.floor)))
+		}
+		if b.ceiling > 0 {
+			rg.desc = append(rg.desc, fmt.Sprintf("%s <= %s", c.name, c.unit(b.ceiling)))
+		}
+	}
+	return rg, nil
+}
+
+// clampMem and clampCPU bound a recommendation and explain the change
+// ("" when it was within bounds).
+func (rg *resultGuardrails) clampMem(current, reco, roundMiB int64) (int64, string) {
+	v, why := rg.mem.clamp(float64(current), float64(reco), float64(roundMiB)*1024*1024, mebibytes)
+	return int64(v), why
+}
+
+func (rg *resultGuardrails) clampCPU(current, reco float64, roundm int64) (float64, string) {
+	return rg.cpu.clamp(current, reco, float64(roundm)/1000, millicores)
+}
+
+// clamp applies the step limits around the current request (rounded back
+// toward it), then the floor and ceiling. Unchanged requests (no data,
+// OOM kills) are left alone, and step limits need a request.
+func (b bounds) clamp(current, reco, step float64, unit func(float64) string) (float64, string) {
+	if reco == current {
+		return reco, ""
+	}
+
+	v, why := reco, ""
+	if current > 0 && b.maxReduce > 0 {
+		if lo := current * (1 - b.maxReduce/100); v < lo {
+			v, why = math.Min(roundUp(lo, step), current), fmt.Sprintf("max -%g%% per run", b.maxReduce)
+		}
+	}
+	if current > 0 && b.maxIncrease > 0 {
+		if hi := current * (1 + b.maxIncrease/100); v > hi {
+			v, why = math.Max(roundDown(hi, step), current), fmt.Sprintf("max +%g%% per run", b.maxIncrease)
+		}
+	}
+	// Bounds never turn a reduction into an increase or the other way round
+	if b.floor > 0 && v < b.floor {
+		v, why = b.floor, "floor "+unit(b.floor)
+		if reco < current {
+			v = math.Min(v, current)
+		}
+	}
+	if b.ceiling > 0 && v > b.ceiling {
+		v, why = b.ceiling, "ceiling "+unit(b.ceiling)
+		if reco > current {
+			v = math.Max(v, current)
+		}
+	}
+
+	if why == "" {
+		return reco, ""
+	}
+	return v, fmt.Sprintf("clamped from %s (%s)", unit(reco), why)
+}
+
+// limit bounds a recommended limit by the floor and ceiling, never below
+// the recommended request.
+func (b bounds) limit(lim, request float64) float64 {
+	if b.floor > 0 {
+		lim = math.Max(lim, b.floor)
+	}
+	if b.ceiling > 0 {
+		lim = math.Min(lim, b.ceiling)
+	}
+	return math.Max(lim, request)
+}
+
+func roundUp(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Ceil(v/step) * step
+}
+
+func roundDown(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Floor(v/step) * step
+}
+
+func mebibytes(b float64) string  { return fmt.Sprintf("%.0fMi", b/(1024*1024)) }
+func millicores(c float64) string { return fmt.Sprintf("%.0fm", c*1000) }
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/policy"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+)
+
+func TestClampMem(t *testing.T) {
+	tests := []struct {
+		name          string
+		g             Guardrails
+		current, reco int64 // MiB
+		want          int64
+		why           string
+	}{
+		{name: "no guardrails", current: 1024, reco: 256, want: 256},
+		{name: "unchanged", g: Guardrails{MemFloorMiB: 512}, current: 256, reco: 256, want: 256},
+		{name: "within step", g: Guardrails{MaxMemReducePct: 50}, current: 1024, reco: 768, want: 768},
+		{name: "reduction capped", g: Guardrails{MaxMemReducePct: 50}, current: 1024, reco: 256, want: 512, why: "clamped from 256Mi (max -50% per run)"},
+		// 1000 * 0.7 = 700Mi, rounded up to 704Mi toward the request
+		{name: "capped and rounded", g: Guardrails{MaxMemReducePct: 30}, current: 1000, reco: 100, want: 704, why: "clamped from 100Mi (max -30% per run)"},
+		{name: "increase capped", g: Guardrails{MaxMemIncreasePct: 100}, current: 1024, reco: 4096, want: 2048, why: "clamped from 4096Mi (max +100% per run)"},
+		{name: "floor", g: Guardrails{MemFloorMiB: 128}, current: 1024, reco: 64, want: 128, why: "clamped from 64Mi (floor 128Mi)"},
+		{name: "floor never turns a reduction into an increase", g: Guardrails{MemFloorMiB: 128}, current: 100, reco: 64, want: 100, why: "clamped from 64Mi (floor 128Mi)"},
+		{name: "ceiling", g: Guardrails{MemCeilingMiB: 2048}, current: 1024, reco: 4096, want: 2048, why: "clamped from 4096Mi (ceiling 2048Mi)"},
+		{name: "ceiling never turns an increase into a reduction", g: Guardrails{MemCeilingMiB: 2048}, current: 3072, reco: 4096, want: 3072, why: "clamped from 4096Mi (ceiling 2048Mi)"},
+		{name: "step limits need a request", g: Guardrails{MaxMemIncreasePct: 10}, current: 0, reco: 512, want: 512},
+		{name: "step then floor", g: Guardrails{MaxMemReducePct: 50, MemFloorMiB: 768}, current: 1024, reco: 256, want: 768, why: "clamped from 256Mi (floor 768Mi)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := compileGuardrails(tt.g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, why := g.clampMem(tt.current*mib, tt.reco*mib, 16)
+			if got != tt.want*mib || why != tt.why {
+				t.Errorf("clampMem = %dMi %q, want %dMi %q", got/mib, why, tt.want, tt.why)
+			}
+		})
+	}
+}
+
+func TestClampCPU(t *testing.T) {
+	tests := []struct {
+		name          string
+		g             Guardrails
+		current, reco float64
+		want          float64
+		why           string
+	}{
+		{name: "reduction capped", g: Guardrails{MaxCPUReducePct: 50}, current: 1, reco: 0.1, want: 0.5, why: "clamped from 100m (max -50% per run)"},
+		{name: "increase capped and rounded down", g: Guardrails{MaxCPUIncreasePct: 33}, current: 0.5, reco: 2, want: 0.66, why: "clamped from 2000m (max +33% per run)"},
+		{name: "floor", g: Guardrails{CPUFloorm: 100}, current: 1, reco: 0.05, want: 0.1, why: "clamped from 50m (floor 100m)"},
+		{name: "ceiling", g: Guardrails{CPUCeilingm: 2000}, current: 1, reco: 3, want: 2, why: "clamped from 3000m (ceiling 2000m)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := compileGuardrails(tt.g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, why := g.clampCPU(tt.current, tt.reco, 10)
+			if !approx(got, tt.want) || why != tt.why {
+				t.Errorf("clampCPU = %v %q, want %v %q", got, why, tt.want, tt.why)
+			}
+		})
+	}
+}
+
+func TestCompileGuardrails(t *testing.T) {
+	g, err := compileGuardrails(Guardrails{MaxMemReducePct: 30, MaxCPUIncreasePct: 50, MemFloorMiB: 64, CPUCeilingm: 4000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"mem -30%", "mem >= 64Mi", "cpu +50%", "cpu <= 4000m"}; !slices.Equal(g.desc, want) {
+		t.Errorf("desc = %q, want %q", g.desc, want)
+	}
+
+	for name, bad := range map[string]Guardrails{
+		"reduce 100%":       {MaxMemReducePct: 100},
+		"negative reduce":   {MaxCPUReducePct: -1},
+		"negative increase": {MaxMemIncreasePct: -5},
+		"negative floor":    {CPUFloorm: -1},
+		"ceiling < floor":   {MemFloorMiB: 512, MemCeilingMiB: 256},
+	} {
+		if _, err := compileGuardrails(bad); err == nil {
+			t.Errorf("%s: compileGuardrails(%+v) succeeded, want an error", name, bad)
+		}
+	}
+}
+
+func TestRunNotesSurvivingClamps(t *testing.T) {
+	keepAll, err := policy.Parse([]byte("rules:\n  - when: \"true\"\n    memory: KEEP\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		policy *policy.Policy
+		want   int64
+		noted  bool
+	}{
+		{name: "clamped reduction", want: 768 * mib, noted: true},
+		{name: "kept by a rule", policy: keepAll, want: gib},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testParams()
+			p.Guardrails = Guardrails{MaxMemReducePct: 25}
+			p.Policy = tt.policy
+			srv := newTestServer(t, nil)
+
+			results, meta, err := NewRightsizeService(srv.Client(t, vm.Config{})).Run(context.Background(), p)
+			if err != nil || len(results) != 1 {
+				t.Fatalf("Run = %d results, %v", len(results), err)
+			}
+			r := results[0]
+			if r.MemRecommendedBytes != tt.want {
+				t.Errorf("memory = %dMi, want %dMi", r.MemRecommendedBytes/mib, tt.want/mib)
+			}
+			if noted := strings.Contains(r.MemoryWhy, "max -25% per run"); noted != tt.noted {
+				t.Errorf("memory why = %q, clamp noted %v, want %v", r.MemoryWhy, noted, tt.noted)
+			}
+			if !slices.Contains(meta.Guardrails, "mem -25%") {
+				t.Errorf("meta guardrails = %q", meta.Guardrails)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// CPU limit strategies (RightsizeParams.CPULimitStrategy).
+const (
+	CPULimitKeep   = "keep"   // leave the current limit as is
+	CPULimitRemove = "remove" // recommend no CPU limit
+	CPULimitRatio  = "ratio"  // size like memory: peak and request ratio
+)
+
+// Defaults for unset limit ratios: memory limit = request (plus peak
+// headroom), CPU limit = 2x request.
+const (
+	defaultMemLimitRatio = 1.0
+	defaultCPULimitRatio = 2.0
+)
+
+func validateCPULimitStrategy(s string) error {
+	switch s {
+	case CPULimitKeep, CPULimitRemove, CPULimitRatio:
+		return nil
+	}
+	return fmt.Errorf("unknown cpu limit strategy %q (want keep|remove|ratio)", s)
+}
+
+// recommendLimits sizes limits from the final request recommendations: the
+// larger of peak usage * safety and request * ratio, within the guardrail
+// floor and ceiling but never below the request. Skipped decisions keep the
+// current limit.
+func recommendLimits(r *model.RightsizeResult, p RightsizeParams, safety float64, g *resultGuardrails) {
+	if strings.HasPrefix(string(r.MemoryDecision), "SKIP") {
+		r.MemLimitRecommendedBytes = r.MemLimitBytes
+	} else {
+		lim := math.Max(float64(r.MemPeakBytes)*safety, float64(r.MemRecommendedBytes)*p.MemLimitRatio)
+		step := float64(p.MemRoundMiB) * 1024 * 1024
+		r.MemLimitRecommendedBytes = int64(g.mem.limit(math.Ceil(lim/step)*step, float64(r.MemRecommendedBytes)))
+	}
+
+	switch {
+	case p.CPULimitStrategy == CPULimitRemove:
+		r.CpuLimitRecommendedCores = 0
+	case p.CPULimitStrategy == CPULimitKeep, strings.HasPrefix(string(r.CPUDecision), "SKIP"):
+		r.CpuLimitRecommendedCores = r.CpuLimitCores
+	default:
+		lim := math.Max(r.CpuPeakCores*safety, r.CpuRecommendedCores*p.CPULimitRatio)
+		step := float64(p.CPURoundm) / 1000.0
+		r.CpuLimitRecommendedCores = g.cpu.limit(math.Ceil(lim/step)*step, r.CpuRecommendedCores)
+	}
+}
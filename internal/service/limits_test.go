@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+const mib = 1 << 20
+
+func TestRecommendLimits(t *testing.T) {
+	base := model.RightsizeResult{
+		MemRecommendedBytes: 512 * mib, MemPeakBytes: 600 * mib, MemLimitBytes: 2 * gib,
+		CpuRecommendedCores: 0.5, CpuPeakCores: 0.8, CpuLimitCores: 4,
+		MemoryDecision: model.MemReduce, CPUDecision: model.CPUReduce,
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(*model.RightsizeResult)
+		strategy   string
+		guardrails Guardrails
+		mem        int64
+		cpu        float64
+	}{
+		// 600Mi * 1.25 rounds up to 752Mi; 0.8 * 1.25 = 1 core
+		{name: "peak wins", strategy: CPULimitRatio, mem: 752 * mib, cpu: 1},
+		{
+			name:     "request ratio wins",
+			mutate:   func(r *model.RightsizeResult) { r.MemPeakBytes, r.CpuPeakCores = 100*mib, 0.1 },
+			strategy: CPULimitRatio, mem: 624 * mib, cpu: 0.6,
+		},
+		{name: "keep cpu limit", strategy: CPULimitKeep, mem: 752 * mib, cpu: 4},
+		{name: "remove cpu limit", strategy: CPULimitRemove, mem: 752 * mib, cpu: 0},
+		{
+			name: "skipped decisions keep current limits",
+			mutate: func(r *model.RightsizeResult) {
+				r.MemoryDecision, r.CPUDecision = model.MemSkipOOM, model.CPUSkipThrottling
+			},
+			strategy: CPULimitRatio, mem: 2 * gib, cpu: 4,
+		},
+		{
+			name:       "ceiling caps limits",
+			strategy:   CPULimitRatio,
+			guardrails: Guardrails{MemCeilingMiB: 600, CPUCeilingm: 700},
+			mem:        600 * mib, cpu: 0.7,
+		},
+		{
+			name:       "never below the request",
+			strategy:   CPULimitRatio,
+			guardrails: Guardrails{MemCeilingMiB: 256, CPUCeilingm: 100},
+			mem:        512 * mib, cpu: 0.5,
+		},
+		{
+			name:       "floor raises limits",
+			strategy:   CPULimitRatio,
+			guardrails: Guardrails{MemFloorMiB: 1024, CPUFloorm: 1500},
+			mem:        gib, cpu: 1.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := compileGuardrails(tt.guardrails)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := RightsizeParams{
+				MemLimitRatio: 1.2, CPULimitRatio: 1.2, CPULimitStrategy: tt.strategy,
+				MemRoundMiB: 16, CPURoundm: 10,
+			}
+			r := base
+			if tt.mutate != nil {
+				tt.mutate(&r)
+			}
+
+			recommendLimits(&r, p, 1.25, g)
+			if r.MemLimitRecommendedBytes != tt.mem {
+				t.Errorf("memory limit = %dMi, want %dMi", r.MemLimitRecommendedBytes/mib, tt.mem/mib)
+			}
+			if !approx(r.CpuLimitRecommendedCores, tt.cpu) {
+				t.Errorf("cpu limit = %v, want %v", r.CpuLimitRecommendedCores, tt.cpu)
+			}
+		})
+	}
+}
+
+func approx(a, b float64) bool {
+	const eps = 1e-9
+	return a-b < eps && b-a < eps
+}
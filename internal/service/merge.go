@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// DefaultDivergence flags services whose usage differs by more than 50%
+// between their busiest and quietest cluster.
+const DefaultDivergence = 0.5
+
+// serviceKey identifies a service regardless of cluster.
+func serviceKey(r model.RightsizeResult) string {
+	parts := []string{r.Namespace, r.WorkloadKind, r.Workload, r.Container}
+	names := make([]string, 0, len(r.Labels))
+	for n := range r.Labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		parts = append(parts, n+"="+r.Labels[n])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// topKServices keeps the rows of the first k distinct services (all clusters).
+func topKServices(results []model.RightsizeResult, k int) []model.RightsizeResult {
+	if k <= 0 {
+		return results
+	}
+	kept := map[string]bool{}
+	out := results[:0:0]
+	for _, r := range results {
+		key := serviceKey(r)
+		if !kept[key] {
+			if len(kept) == k {
+				continue
+			}
+			kept[key] = true
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// MergeClusters folds per-cluster results into one row per service, keeping
+// the order in which services first appear. Memory fields come from the
+// cluster with the highest memory recommendation and CPU fields from the one
+// with the highest CPU recommendation, so the merged values fit everywhere.
+func MergeClusters(results []model.RightsizeResult, divergence float64) []model.MergedResult {
+	idx := map[string]int{}
+	var groups [][]model.RightsizeResult
+	for _, r := range results {
+		k := serviceKey(r)
+		i, ok := idx[k]
+		if !ok {
+			i = len(groups)
+			idx[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+
+	out := make([]model.MergedResult, 0, len(groups))
+	for _, rows := range groups {
+		out = append(out, mergeService(rows, divergence))
+	}
+	return out
+}
+
+func mergeService(rows []model.RightsizeResult, divergence float64) model.MergedResult {
+	mem, cpu, least := rows[0], rows[0], rows[0]
+	clusters := make([]string, 0, len(rows))
+	memUsage := make([]float64, 0, len(rows))
+	cpuUsage := make([]float64, 0, len(rows))
+
+	for _, r := range rows {
+		clusters = append(clusters, r.Cluster)
+		memUsage = append(memUsage, r.MemUsageRatio*float64(r.MemRequestBytes))
+		cpuUsage = append(cpuUsage, r.CpuUsageRatio*r.CpuRequestCores)
+
+		if r.MemRecommendedBytes > mem.MemRecommendedBytes {
+			mem = r
+		}
+		if r.CpuRecommendedCores > cpu.CpuRecommendedCores {
+			cpu = r
+		}
+		if confidenceRank[r.Confidence] < confidenceRank[least.Confidence] {
+			least = r
+		}
+	}
+	sort.Strings(clusters)
+
+	m := model.MergedResult{
+		RightsizeResult: mem,
+		Clusters:        clusters,
+		MemUsageSpread:  spread(memUsage),
+		CpuUsageSpread:  spread(cpuUsage),
+	}
+	m.Diverges = m.MemUsageSpread-1 > divergence || m.CpuUsageSpread-1 > divergence
+
+	r := &m.RightsizeResult
+	r.Cluster = strings.Join(clusters, ",")
+
+	// Replicas, totals and savings add up over clusters, each at its own sizing
+	r.Replicas, r.ReplicasAvg, r.ReplicasPeak = 0, 0, 0
+	r.MemDeltaTotalBytes, r.CpuDeltaTotalCores = 0, 0
+	r.EstSavingsPerHourUSD, r.EstSavingsPerMonthUSD = 0, 0
+	for _, row := range rows {
+		r.Replicas += row.Replicas
+		r.ReplicasAvg += row.ReplicasAvg
+		r.ReplicasPeak += row.ReplicasPeak
+		r.MemDeltaTotalBytes += row.MemDeltaTotalBytes
+		r.CpuDeltaTotalCores += row.CpuDeltaTotalCores
+		r.EstSavingsPerHourUSD += row.EstSavingsPerHourUSD
+		r.EstSavingsPerMonthUSD += row.EstSavingsPerMonthUSD
+	}
+
+	r.CpuUsageRatio = cpu.CpuUsageRatio
+	r.CpuUsageStats = cpu.CpuUsageStats
+	r.CpuRequestCores = cpu.CpuRequestCores
+	r.CpuRecommendedCores = cpu.CpuRecommendedCores
+	r.CpuDeltaCores = cpu.CpuDeltaCores
+	r.CpuLimitCores = cpu.CpuLimitCores
+	r.CpuLimitRecommendedCores = cpu.CpuLimitRecommendedCores
+	r.CpuPeakCores = cpu.CpuPeakCores
+	r.CPUThrottled = cpu.CPUThrottled
+	r.CPUDecision = cpu.CPUDecision
+	r.CPUWhy = cpu.CPUWhy
+
+	// As confident as the least confident cluster
+	r.Coverage, r.PodAgeHours, r.Restarts = least.Coverage, least.PodAgeHours, least.Restarts
+	r.Confidence, r.ConfidenceWhy = least.Confidence, least.ConfidenceWhy
+
+	if len(rows) > 1 {
+		r.MemoryWhy = fmt.Sprintf("%s (sized for %s)", mem.MemoryWhy, mem.Cluster)
+		r.CPUWhy = fmt.Sprintf("%s (sized for %s)", cpu.CPUWhy, cpu.Cluster)
+		if r.ConfidenceWhy != "" {
+			r.ConfidenceWhy = fmt.Sprintf("%s (in %s)", r.ConfidenceWhy, least.Cluster)
+		}
+	}
+	return m
+}
+
+// confidenceRank orders confidence levels; ungraded ("") ranks above all.
+var confidenceRank = map[model.Confidence]int{
+	model.ConfidenceLow:    1,
+	model.ConfidenceMedium: 2,
+	model.ConfidenceHigh:   3,
+	"":                     4,
+}
+
+// spread returns max/min of positive values (1 when there is nothing to compare).
+func spread(vals []float64) float64 {
+	lo, hi := math.Inf(1), 0.0
+	for _, v := range vals {
+		if v <= 0 {
+			continue
+		}
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	if hi == 0 {
+		return 1
+	}
+	return hi / lo
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+func clusterRow(cluster, container string, memRec int64, cpuRec float64) model.RightsizeResult {
+	return model.RightsizeResult{
+		Namespace: testNamespace, Cluster: cluster, Container: container,
+		MemUsageRatio: 0.5, MemRequestBytes: gib, MemRecommendedBytes: memRec,
+		CpuUsageRatio: 0.5, CpuRequestCores: 1, CpuRecommendedCores: cpuRec,
+		Replicas: 2, ReplicasAvg: 2, ReplicasPeak: 3,
+		Confidence: model.ConfidenceHigh,
+		MemoryWhy:  "mem in " + cluster, CPUWhy: "cpu in " + cluster,
+	}
+}
+
+func TestMergeClusters(t *testing.T) {
+	b := clusterRow("b", "api", 2*gib, 0.5)
+	a := clusterRow("a", "api", gib, 2)
+	a.Confidence, a.ConfidenceWhy = model.ConfidenceLow, "pods younger than 24h"
+	other := clusterRow("a", "worker", gib, 1)
+
+	merged := MergeClusters([]model.RightsizeResult{b, other, a}, DefaultDivergence)
+	if len(merged) != 2 {
+		t.Fatalf("got %d merged rows, want 2", len(merged))
+	}
+	if merged[0].Container != "api" || merged[1].Container != "worker" {
+		t.Fatalf("order = %s, %s; want services in first-seen order", merged[0].Container, merged[1].Container)
+	}
+
+	m := merged[0]
+	if !slices.Equal(m.Clusters, []string{"a", "b"}) || m.Cluster != "a,b" {
+		t.Errorf("clusters = %v (%q), want [a b]", m.Clusters, m.Cluster)
+	}
+	if m.MemRecommendedBytes != 2*gib || !strings.Contains(m.MemoryWhy, "sized for b") {
+		t.Errorf("memory = %d (%q), want the larger recommendation from b", m.MemRecommendedBytes, m.MemoryWhy)
+	}
+	if m.CpuRecommendedCores != 2 || !strings.Contains(m.CPUWhy, "sized for a") {
+		t.Errorf("cpu = %v (%q), want the larger recommendation from a", m.CpuRecommendedCores, m.CPUWhy)
+	}
+	if m.Replicas != 4 || m.ReplicasAvg != 4 || m.ReplicasPeak != 6 {
+		t.Errorf("replicas = %v/%v/%v, want the sum over clusters", m.Replicas, m.ReplicasAvg, m.ReplicasPeak)
+	}
+	if m.Confidence != model.ConfidenceLow || !strings.Contains(m.ConfidenceWhy, "in a") {
+		t.Errorf("confidence = %s (%q), want the least confident cluster", m.Confidence, m.ConfidenceWhy)
+	}
+	if m.Diverges {
+		t.Errorf("identical usage flagged as diverging (spreads %v, %v)", m.MemUsageSpread, m.CpuUsageSpread)
+	}
+}
+
+func TestMergeClustersDivergence(t *testing.T) {
+	busy := clusterRow("a", "api", gib, 1)
+	quiet := clusterRow("b", "api", gib, 1)
+	quiet.MemUsageRatio = 0.25
+
+	m := MergeClusters([]model.RightsizeResult{busy, quiet}, DefaultDivergence)[0]
+	if m.MemUsageSpread != 2 || !m.Diverges {
+		t.Errorf("memory spread = %v (diverges %v), want 2 and diverging", m.MemUsageSpread, m.Diverges)
+	}
+	if m := MergeClusters([]model.RightsizeResult{busy, quiet}, 1.5)[0]; m.Diverges {
+		t.Errorf("spread %v flagged above a divergence of 1.5", m.MemUsageSpread)
+	}
+}
+
+func TestTopKServicesKeepsEveryCluster(t *testing.T) {
+	rows := []model.RightsizeResult{
+		clusterRow("a", "api", gib, 1),
+		clusterRow("a", "worker", gib, 1),
+		clusterRow("b", "api", gib, 1),
+		clusterRow("b", "cron", gib, 1),
+	}
+
+	got := topKServices(rows, 2)
+	var keys []string
+	for _, r := range got {
+		keys = append(keys, r.Cluster+"/"+r.Container)
+	}
+	if want := []string{"a/api", "a/worker", "b/api"}; !slices.Equal(keys, want) {
+		t.Errorf("kept %v, want %v", keys, want)
+	}
+	if got := topKServices(rows, 0); len(got) != len(rows) {
+		t.Errorf("k=0 kept %d rows, want all %d", len(got), len(rows))
+	}
+}
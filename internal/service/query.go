@@ -12,11 +12,11 @@ type QueryInput struct {
 }
 
 type QueryService struct {
-	client *vm.Client
+	ds vm.Datasource
 }
 
-func NewQueryService(vmURL string) *QueryService {
+func NewQueryService(ds vm.Datasource) *QueryService {
 	return &QueryService{
-		client: vm.NewClient(vmURL),
+		ds: ds,
 	}
 }
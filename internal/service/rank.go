@@ -0,0 +1,108 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+)
+
+// SortKey orders results by one field; later keys break ties of earlier ones.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+func (k SortKey) String() string {
+	if k.Desc {
+		return k.Field + ":desc"
+	}
+	return k.Field + ":asc"
+}
+
+// sortField compares two results ascending; desc is the direction used
+// when a key names none, chosen so the most worth acting on comes first.
+type sortField struct {
+	cmp  func(a, b model.RightsizeResult) int
+	desc bool
+}
+
+// Deltas and reclaims are negative for reductions, so ascending lists the
+// largest reductions first.
+var sortFields = map[string]sortField{
+	"mem-ratio": {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.MemUsageRatio, b.MemUsageRatio) }},
+	"cpu-ratio": {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.CpuUsageRatio, b.CpuUsageRatio) }},
+	"mem-delta": {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.MemDeltaBytes, b.MemDeltaBytes) }},
+	"cpu-delta": {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.CpuDeltaCores, b.CpuDeltaCores) }},
+	"mem-reclaim": {cmp: func(a, b model.RightsizeResult) int {
+		return cmp.Compare(a.MemDeltaTotalBytes, b.MemDeltaTotalBytes)
+	}},
+	"cpu-reclaim": {cmp: func(a, b model.RightsizeResult) int {
+		return cmp.Compare(a.CpuDeltaTotalCores, b.CpuDeltaTotalCores)
+	}},
+	"savings": {cmp: func(a, b model.RightsizeResult) int {
+		return cmp.Compare(a.EstSavingsPerHourUSD, b.EstSavingsPerHourUSD)
+	}, desc: true},
+	"replicas": {cmp: func(a, b model.RightsizeResult) int {
+		return cmp.Compare(replicaCount(a), replicaCount(b))
+	}, desc: true},
+	"namespace": {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.Namespace, b.Namespace) }},
+	"workload":  {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.WorkloadRef(), b.WorkloadRef()) }},
+	"container": {cmp: func(a, b model.RightsizeResult) int { return cmp.Compare(a.Container, b.Container) }},
+}
+
+// DefaultSortBy lists the most overprovisioned memory first.
+var DefaultSortBy = []SortKey{{Field: "mem-ratio"}}
+
+// SortFields returns the sortable field names.
+func SortFields() []string {
+	names := make([]string, 0, len(sortFields))
+	for n := range sortFields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSortKeys parses "field[:asc|desc]" specs, e.g. savings,mem-ratio:desc.
+// A field without a direction sorts in its natural one.
+func ParseSortKeys(specs []string) ([]SortKey, error) {
+	keys := make([]SortKey, 0, len(specs))
+	for _, spec := range specs {
+		field, dir, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		f, ok := sortFields[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q (want %s)", field, strings.Join(SortFields(), "|"))
+		}
+		k := SortKey{Field: field, Desc: f.desc}
+		switch dir {
+		case "":
+		case "asc":
+			k.Desc = false
+		case "desc":
+			k.Desc = true
+		default:
+			return nil, fmt.Errorf("sort field %s: unknown direction %q (want asc|desc)", field, dir)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// compareBy orders results by keys in turn.
+func compareBy(keys []SortKey) func(a, b model.RightsizeResult) int {
+	return func(a, b model.RightsizeResult) int {
+		for _, k := range keys {
+			c := sortFields[k.Field].cmp(a, b)
+			if k.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
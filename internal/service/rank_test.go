@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/pricing"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/promql"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm/vmtest"
+)
+
+func TestReplicaCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		now, avg     float64
+		wantReplicas float64
+	}{
+		{name: "window average", now: 5, avg: 3.5, wantReplicas: 3.5},
+		{name: "current without an average", now: 5, wantReplicas: 5},
+		{name: "unknown counts once", wantReplicas: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := model.RightsizeResult{Replicas: tt.now, ReplicasAvg: tt.avg}
+			if got := replicaCount(r); got != tt.wantReplicas {
+				t.Errorf("replicaCount = %v, want %v", got, tt.wantReplicas)
+			}
+		})
+	}
+}
+
+func TestRunTotalsAcrossReplicas(t *testing.T) {
+	p := testParams()
+	window, subStep := promql.MustDuration(p.Window), promql.MustDuration(p.SubqueryStep)
+	prices, err := pricing.Parse([]byte("rates:\n  on-demand: {vcpu-hour: 0.04, gib-hour: 0.005}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Pricing = prices
+
+	srv := newTestServer(t, func(s *vmtest.Server) {
+		s.HandleExpr(testQueries.Replicas(p.Namespaces, testCluster), vmtest.Vector(sample(4)))
+		s.HandleExpr(testQueries.ReplicasAvg(p.Namespaces, testCluster, window, subStep), vmtest.Vector(sample(3)))
+		s.HandleExpr(testQueries.ReplicasPeak(p.Namespaces, testCluster, window, subStep), vmtest.Vector(sample(6)))
+	})
+	results, _, err := NewRightsizeService(srv.Client(t, vm.Config{})).Run(context.Background(), p)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Run = %d results, %v", len(results), err)
+	}
+
+	r := results[0]
+	if r.Replicas != 4 || r.ReplicasAvg != 3 || r.ReplicasPeak != 6 {
+		t.Errorf("replicas = %v/%v/%v, want 4/3/6", r.Replicas, r.ReplicasAvg, r.ReplicasPeak)
+	}
+	if r.MemDeltaBytes >= 0 || r.MemDeltaTotalBytes != 3*r.MemDeltaBytes {
+		t.Errorf("memory delta total = %d, want 3 x %d", r.MemDeltaTotalBytes, r.MemDeltaBytes)
+	}
+	if math.Abs(r.CpuDeltaTotalCores-3*r.CpuDeltaCores) > 1e-9 {
+		t.Errorf("cpu delta total = %v, want 3 x %v", r.CpuDeltaTotalCores, r.CpuDeltaCores)
+	}
+
+	rate, _ := prices.For(testCluster, testNamespace, "", "api")
+	want := rate.Savings(r.CpuDeltaCores, r.MemDeltaBytes, 3)
+	if want <= 0 || math.Abs(r.EstSavingsPerHourUSD-want) > 1e-12 {
+		t.Errorf("savings = %v/h, want %v/h (positive for a reduction)", r.EstSavingsPerHourUSD, want)
+	}
+	if math.Abs(r.EstSavingsPerMonthUSD-want*pricing.HoursPerMonth) > 1e-9 {
+		t.Errorf("savings = %v/month, want %v", r.EstSavingsPerMonthUSD, want*pricing.HoursPerMonth)
+	}
+	if r.PriceTier != pricing.OnDemand {
+		t.Errorf("price tier = %q, want %q", r.PriceTier, pricing.OnDemand)
+	}
+}
+
+func TestParseSortKeys(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		want    []SortKey
+		wantErr bool
+	}{
+		{specs: []string{"mem-ratio"}, want: []SortKey{{Field: "mem-ratio"}}},
+		// savings and replicas sort descending unless told otherwise
+		{specs: []string{"savings", "replicas:asc"}, want: []SortKey{{Field: "savings", Desc: true}, {Field: "replicas"}}},
+		{specs: []string{" cpu-delta:desc "}, want: []SortKey{{Field: "cpu-delta", Desc: true}}},
+		{specs: []string{"memory"}, wantErr: true},
+		{specs: []string{"mem-ratio:down"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.specs), func(t *testing.T) {
+			got, err := ParseSortKeys(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSortKeys = %v, %v; want error %v", got, err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("ParseSortKeys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareBy(t *testing.T) {
+	row := func(name, ns string, memRatio, savings float64) model.RightsizeResult {
+		return model.RightsizeResult{Container: name, Namespace: ns, MemUsageRatio: memRatio, EstSavingsPerHourUSD: savings}
+	}
+	rows := []model.RightsizeResult{
+		row("a", "shop", 0.5, 1),
+		row("b", "billing", 0.2, 3),
+		row("c", "shop", 0.2, 2),
+		row("d", "billing", 0.5, 3),
+	}
+
+	tests := []struct {
+		specs []string
+		want  string
+	}{
+		{specs: []string{"mem-ratio", "container"}, want: "bcad"},
+		{specs: []string{"mem-ratio:desc", "container"}, want: "adbc"},
+		{specs: []string{"savings", "container:desc"}, want: "dbca"},
+		{specs: []string{"namespace", "mem-ratio", "container"}, want: "bdca"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.specs), func(t *testing.T) {
+			keys, err := ParseSortKeys(tt.specs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sorted := slices.Clone(rows)
+			slices.SortStableFunc(sorted, compareBy(keys))
+			var got string
+			for _, r := range sorted {
+				got += r.Container
+			}
+			if got != tt.want {
+				t.Errorf("order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
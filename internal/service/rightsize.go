@@ -4,40 +4,153 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"sort"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/policy"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/pricing"
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/promql"
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/service/decision"
 	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
 )
 
 type RightsizeParams struct {
-	Namespace    string
-	Cluster      string
+	Namespaces   promql.NamespaceSelector
+	Clusters     []string
+	ClusterRegex string // discover clusters by regex (overrides Clusters)
 	Window       string
 	SubqueryStep string
 
 	OOMWindow string
 
+	// Usage statistics the ratios are sized from ("" = p95), and extra
+	// statistics fetched for display only
+	MemStat    promql.Stat
+	CPUStat    promql.Stat
+	ExtraStats []promql.Stat
+
+	// Mode: ratio scales current requests by usage/request, absolute sizes
+	// from absolute usage so containers without requests are sized too
+	// ("" = ratio)
+	Mode string
+
 	TargetUtil   float64
 	SafetyFactor float64
 	MemRoundMiB  int64
 	CPURoundm    int64
 
+	// Largest change per run and absolute bounds on recommendations
+	Guardrails Guardrails
+
+	// Rows to keep, then their ordering (nil: DefaultSortBy) and TopK
+	// services per section
+	Filter Filter
+	SortBy []SortKey
 	TopK   int
-	Bottom bool
+
+	// Label schema (zero value: uw_cluster, no extras)
+	Labels promql.LabelSchema
+
+	// Sidecars: containers whose name fully matches one of these RE2
+	// patterns, sized with SidecarSafety (0 = SafetyFactor) for extra headroom
+	SidecarPatterns []string
+	SidecarSafety   float64
+
+	// Decision bands and overrides (nil: built-in policy)
+	Policy *policy.Policy
+
+	// LowConfidence: keep downgrades low-confidence changes to KEEP,
+	// report only grades them ("" = keep)
+	LowConfidence string
+
+	// Rates for savings estimates (nil: no estimates)
+	Pricing *pricing.Pricing
+
+	// Limits: limit-to-request ratios (0 = defaults) and CPU limit
+	// strategy (keep|remove|ratio, "" = keep)
+	MemLimitRatio    float64
+	CPULimitRatio    float64
+	CPULimitStrategy string
+
+	// Fetching: max queries in flight and per-query timeout (0 = defaults)
+	Concurrency  int
+	QueryTimeout time.Duration
 }
 
-type RightsizeService struct {
-	vm *vm.Client
+// Result keys (RightsizeMeta.GroupBy): per workload and container, or per
+// container name alone.
+const (
+	GroupByWorkload  = "workload"
+	GroupByContainer = "container"
+)
+
+// Recommendation modes (RightsizeMeta.Mode).
+const (
+	ModeRatio    = "ratio"
+	ModeAbsolute = "absolute"
+)
+
+// Low-confidence handling (RightsizeMeta.LowConfidence).
+const (
+	LowConfidenceKeep   = "keep"
+	LowConfidenceReport = "report"
+)
+
+// Signal names, used in errors and model.RightsizeMeta.FailedSignals.
+const (
+	sigMemReq         = "mem requests"
+	sigCPUReq         = "cpu requests"
+	sigOOM            = "oom killed"
+	sigCPUThrottle    = "cpu throttling"
+	sigJVMHeapAfterGC = "jvm heap after gc"
+	sigJVMNonHeap     = "jvm non-heap"
+	sigInitMemReq     = "init mem requests"
+	sigInitCPUReq     = "init cpu requests"
+	sigInitMemPeak    = "init mem peak"
+	sigInitCPUPeak    = "init cpu peak"
+	sigMemLimits      = "mem limits"
+	sigCPULimits      = "cpu limits"
+	sigMemPeak        = "mem peak"
+	sigCPUPeak        = "cpu peak"
+	sigReplicas       = "replicas"
+	sigReplicasAvg    = "replicas avg"
+	sigReplicasPeak   = "replicas peak"
+	sigCoverage       = "coverage"
+	sigPodAge         = "pod age"
+	sigRestarts       = "restarts"
+)
+
+// Usage ratio signals are named after their statistic, e.g. "mem p95 ratio".
+func sigMemRatio(stat promql.Stat) string { return "mem " + string(stat) + " ratio" }
+func sigCPURatio(stat promql.Stat) string { return "cpu " + string(stat) + " ratio" }
+func sigMemUsage(stat promql.Stat) string { return "mem " + string(stat) + " usage" }
+func sigCPUUsage(stat promql.Stat) string { return "cpu " + string(stat) + " usage" }
+
+// DefaultSidecarPatterns matches common mesh proxies, secret agents and log shippers.
+var DefaultSidecarPatterns = []string{
+	"istio-proxy",
+	"linkerd-proxy",
+	"envoy",
+	"vault-agent",
+	"cloud-sql-proxy",
+	"fluent-bit",
+	"fluentd",
+	"filebeat",
+	"promtail",
+	".*-sidecar",
 }
 
-func NewRightsizeService() *RightsizeService {
-	const vmURL = "http://vmselect.management.prod.internal:8481/select/0/prometheus/api/v1"
+type RightsizeService struct {
+	ds vm.Datasource
+}
 
+func NewRightsizeService(ds vm.Datasource) *RightsizeService {
 	return &RightsizeService{
-		vm: vm.NewClient(vmURL),
+		ds: ds,
 	}
 }
 
@@ -47,8 +160,7 @@ func (s *RightsizeService) Run(
 ) ([]model.RightsizeResult, model.RightsizeMeta, error) {
 
 	meta := model.RightsizeMeta{
-		Namespace:    p.Namespace,
-		Cluster:      p.Cluster,
+		Namespace:    p.Namespaces.String(),
 		Window:       p.Window,
 		OOMWindow:    p.OOMWindow,
 		TargetUtil:   p.TargetUtil,
@@ -56,96 +168,486 @@ func (s *RightsizeService) Run(
 		SubqueryStep: p.SubqueryStep,
 	}
 
-	// ---------------------------------------------------------------------
-	// 1. Fetch signals (best-effort where appropriate)
-	// ---------------------------------------------------------------------
+	if err := p.Namespaces.Validate(); err != nil {
+		return nil, meta, err
+	}
+
+	labels := p.Labels.WithDefaults()
+	if err := labels.Validate(); err != nil {
+		return nil, meta, err
+	}
+	meta.ClusterLabel = labels.Cluster
+	meta.IdentityLabels = labels.Identity()
+	meta.GroupBy = GroupByContainer
+	if labels.Workloads {
+		meta.GroupBy = GroupByWorkload
+	}
+
+	sidecars, err := compileSidecars(p.SidecarPatterns)
+	if err != nil {
+		return nil, meta, err
+	}
+	meta.SidecarPatterns = p.SidecarPatterns
 
-	memP95, err := s.query(ctx, promql.MemP95Ratio(
-		p.Namespace, p.Cluster, p.Window, p.SubqueryStep,
-	))
+	if p.MemStat == "" {
+		p.MemStat = promql.StatP95
+	}
+	if p.CPUStat == "" {
+		p.CPUStat = promql.StatP95
+	}
+	for _, st := range append([]promql.Stat{p.MemStat, p.CPUStat}, p.ExtraStats...) {
+		if _, err := promql.ParseStat(string(st)); err != nil {
+			return nil, meta, err
+		}
+	}
+	meta.MemStat = string(p.MemStat)
+	meta.CPUStat = string(p.CPUStat)
+	for _, st := range p.ExtraStats {
+		meta.ExtraStats = append(meta.ExtraStats, string(st))
+	}
+
+	pol := p.Policy
+	if pol == nil {
+		pol = policy.Default()
+	}
+	meta.Policy = pol.Meta
+
+	if p.Mode == "" {
+		p.Mode = ModeRatio
+	}
+	if p.Mode != ModeRatio && p.Mode != ModeAbsolute {
+		return nil, meta, fmt.Errorf("unknown mode %q (want %s|%s)", p.Mode, ModeRatio, ModeAbsolute)
+	}
+	meta.Mode = p.Mode
+
+	if p.LowConfidence == "" {
+		p.LowConfidence = LowConfidenceKeep
+	}
+	if p.LowConfidence != LowConfidenceKeep && p.LowConfidence != LowConfidenceReport {
+		return nil, meta, fmt.Errorf("unknown low-confidence handling %q (want %s|%s)", p.LowConfidence, LowConfidenceKeep, LowConfidenceReport)
+	}
+	meta.LowConfidence = p.LowConfidence
+
+	guardrails, err := compileGuardrails(p.Guardrails)
 	if err != nil {
-		return nil, meta, fmt.Errorf("mem p95 ratio: %w", err)
+		return nil, meta, err
 	}
+	meta.Guardrails = guardrails.desc
 
-	cpuP95, err := s.query(ctx, promql.CpuP95Ratio(
-		p.Namespace, p.Cluster, p.Window, p.SubqueryStep,
-	))
+	filter, err := compileFilter(p.Filter)
 	if err != nil {
-		return nil, meta, fmt.Errorf("cpu p95 ratio: %w", err)
+		return nil, meta, err
+	}
+	meta.Filters = filter.desc
+
+	if len(p.SortBy) == 0 {
+		p.SortBy = DefaultSortBy
+	}
+	for _, k := range p.SortBy {
+		if _, ok := sortFields[k.Field]; !ok {
+			return nil, meta, fmt.Errorf("unknown sort field %q (want %s)", k.Field, strings.Join(SortFields(), "|"))
+		}
+		meta.SortBy = append(meta.SortBy, k.String())
+	}
+
+	if p.Pricing != nil {
+		meta.Pricing = &p.Pricing.Meta
+	}
+
+	if p.MemLimitRatio <= 0 {
+		p.MemLimitRatio = defaultMemLimitRatio
+	}
+	if p.CPULimitRatio <= 0 {
+		p.CPULimitRatio = defaultCPULimitRatio
+	}
+	if p.CPULimitStrategy == "" {
+		p.CPULimitStrategy = CPULimitKeep
+	}
+	if err := validateCPULimitStrategy(p.CPULimitStrategy); err != nil {
+		return nil, meta, err
+	}
+	meta.MemLimitRatio = p.MemLimitRatio
+	meta.CPULimitRatio = p.CPULimitRatio
+	meta.CPULimitStrategy = p.CPULimitStrategy
+
+	rp := runPlan{
+		params:     p,
+		labels:     labels,
+		q:          promql.NewRightsizeQueries(labels),
+		sidecars:   sidecars,
+		policy:     pol,
+		guardrails: guardrails,
+	}
+
+	if rp.window, err = promql.ParseDuration(p.Window); err != nil {
+		return nil, meta, fmt.Errorf("window: %w", err)
+	}
+	if rp.subStep, err = promql.ParseDuration(p.SubqueryStep); err != nil {
+		return nil, meta, fmt.Errorf("sub-step: %w", err)
+	}
+	if rp.oomWindow, err = promql.ParseDuration(p.OOMWindow); err != nil {
+		return nil, meta, fmt.Errorf("oom-window: %w", err)
 	}
 
-	memReq, err := s.query(ctx, promql.MemRequests(p.Namespace, p.Cluster))
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	// One limit shared by every cluster, so fan-out never multiplies load
+	rp.sem = make(chan struct{}, concurrency)
+
+	// ---------------------------------------------------------------------
+	// 0. Resolve clusters (explicit list or regex discovery)
+	// ---------------------------------------------------------------------
+
+	clusters, err := s.resolveClusters(ctx, rp)
 	if err != nil {
-		return nil, meta, fmt.Errorf("mem requests: %w", err)
+		return nil, meta, err
+	}
+	meta.Cluster = strings.Join(clusters, ",")
+	meta.Clusters = clusters
+
+	// ---------------------------------------------------------------------
+	// 1-5. Fetch, join and decide per cluster, all clusters concurrently
+	// ---------------------------------------------------------------------
+
+	type clusterRun struct {
+		results []model.RightsizeResult
+		report  fetchReport
+		err     error
+	}
+	runs := make([]clusterRun, len(clusters))
+
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, report, err := s.runCluster(ctx, rp, cluster)
+			runs[i] = clusterRun{results: res, report: report, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var results []model.RightsizeResult
+	multi := len(clusters) > 1
+	for i, run := range runs {
+		if run.err != nil {
+			if multi {
+				return nil, meta, fmt.Errorf("cluster %s: %w", clusters[i], run.err)
+			}
+			return nil, meta, run.err
+		}
+		results = append(results, run.results...)
+
+		for _, f := range run.report.failed {
+			if multi {
+				f.Signal = clusters[i] + ": " + f.Signal
+			}
+			meta.FailedSignals = append(meta.FailedSignals, f)
+		}
+		for _, w := range run.report.warnings {
+			if multi {
+				w = clusters[i] + ": " + w
+			}
+			meta.Warnings = append(meta.Warnings, w)
+		}
+	}
+
+	// ---------------------------------------------------------------------
+	// 6. Rank results
+	// ---------------------------------------------------------------------
+
+	results, meta.Filtered = filter.apply(results)
+	slices.SortStableFunc(results, compareBy(p.SortBy))
+
+	// TopK counts services per role (sidecars and init containers never
+	// crowd out apps), not rows: a service kept in one cluster keeps its
+	// rows in every cluster so the cross-cluster merge stays complete.
+	var ranked []model.RightsizeResult
+	for _, role := range []model.ContainerRole{model.RoleApp, model.RoleSidecar, model.RoleInit} {
+		ranked = append(ranked, topKServices(withRole(results, role), p.TopK)...)
+	}
+	return ranked, meta, nil
+}
+
+// runPlan is the validated, per-run state shared by every cluster.
+type runPlan struct {
+	params RightsizeParams
+	labels promql.LabelSchema
+	q      promql.RightsizeQueries
+
+	window, subStep, oomWindow promql.Duration
+
+	sidecars   *regexp.Regexp // nil: no sidecar patterns
+	policy     *policy.Policy
+	guardrails *resultGuardrails
+
+	sem chan struct{}
+}
+
+func (s *RightsizeService) resolveClusters(ctx context.Context, rp runPlan) ([]string, error) {
+	p := rp.params
+	if p.ClusterRegex == "" {
+		if len(p.Clusters) == 0 {
+			return nil, fmt.Errorf("no cluster selected (use --cluster or --cluster-regex)")
+		}
+		return p.Clusters, nil
 	}
 
-	cpuReq, err := s.query(ctx, promql.CpuRequests(p.Namespace, p.Cluster))
+	if err := promql.ValidateRegexp(p.ClusterRegex); err != nil {
+		return nil, fmt.Errorf("cluster regex: %w", err)
+	}
+	samples, _, err := s.query(ctx, rp.q.ClusterNames(p.Namespaces, p.ClusterRegex))
 	if err != nil {
-		return nil, meta, fmt.Errorf("cpu requests: %w", err)
+		return nil, fmt.Errorf("discover clusters: %w", err)
+	}
+
+	var clusters []string
+	for _, smp := range samples {
+		if c := smp.Metric[rp.labels.Cluster]; c != "" {
+			clusters = append(clusters, c)
+		}
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no cluster matches %s=~%q", rp.labels.Cluster, p.ClusterRegex)
 	}
+	slices.Sort(clusters)
+	return clusters, nil
+}
 
-	oom, _ := s.query(ctx, promql.OOMKilled(
-		p.Namespace, p.Cluster, p.OOMWindow,
-	))
+// runCluster fetches all signals for one cluster and builds its results.
+func (s *RightsizeService) runCluster(
+	ctx context.Context,
+	rp runPlan,
+	cluster string,
+) ([]model.RightsizeResult, fetchReport, error) {
+	p, q, labels := rp.params, rp.q, rp.labels
+	ns := p.Namespaces
 
-	cpuThrottle, _ := s.query(ctx, promql.CPUThrottling(
-		p.Namespace, p.Cluster, p.Window,
-	))
+	// ---------------------------------------------------------------------
+	// 1. Fetch signals concurrently (optional ones are best-effort)
+	// ---------------------------------------------------------------------
 
-	jvmHeapAfterGC, _ := s.query(ctx, promql.JVMHeapAfterGC(
-		p.Namespace, p.Cluster,
-	))
+	abs := p.Mode == ModeAbsolute
+	memSig, _ := rp.usageSignals(ns, cluster, p.MemStat)
+	_, cpuSig := rp.usageSignals(ns, cluster, p.CPUStat)
 
-	jvmNonHeap, _ := s.query(ctx, promql.JVMNonHeapBytes(
-		p.Namespace, p.Cluster,
-	))
+	sigs := []signal{
+		memSig,
+		cpuSig,
+		{name: sigMemReq, expr: q.MemRequests(ns, cluster)},
+		{name: sigCPUReq, expr: q.CpuRequests(ns, cluster)},
+		{name: sigOOM, expr: q.OOMKilled(ns, cluster, rp.oomWindow), optional: true},
+		{name: sigCPUThrottle, expr: q.CPUThrottling(ns, cluster, rp.window), optional: true},
+		{name: sigJVMHeapAfterGC, expr: q.JVMHeapAfterGC(ns, cluster), optional: true},
+		{name: sigJVMNonHeap, expr: q.JVMNonHeapBytes(ns, cluster), optional: true},
+		{name: sigInitMemReq, expr: q.InitMemRequests(ns, cluster), optional: true},
+		{name: sigInitCPUReq, expr: q.InitCpuRequests(ns, cluster), optional: true},
+		{name: sigInitMemPeak, expr: q.InitMemPeak(ns, cluster, rp.window), optional: true},
+		{name: sigInitCPUPeak, expr: q.InitCpuPeak(ns, cluster, rp.window, rp.subStep), optional: true},
+		{name: sigMemLimits, expr: q.MemLimits(ns, cluster), optional: true},
+		{name: sigCPULimits, expr: q.CpuLimits(ns, cluster), optional: true},
+		{name: sigMemPeak, expr: q.MemPeak(ns, cluster, rp.window), optional: true},
+		{name: sigCPUPeak, expr: q.CpuPeak(ns, cluster, rp.window, rp.subStep), optional: true},
+		{name: sigReplicas, expr: q.Replicas(ns, cluster), optional: true},
+		{name: sigReplicasAvg, expr: q.ReplicasAvg(ns, cluster, rp.window, rp.subStep), optional: true},
+		{name: sigReplicasPeak, expr: q.ReplicasPeak(ns, cluster, rp.window, rp.subStep), optional: true},
+		{name: sigCoverage, expr: q.Coverage(ns, cluster, rp.window, rp.subStep), optional: true},
+		{name: sigPodAge, expr: q.PodAge(ns, cluster), optional: true},
+		{name: sigRestarts, expr: q.Restarts(ns, cluster, rp.window), optional: true},
+	}
+
+	// Extra statistics are display-only; the sizing ones are already fetched
+	for _, st := range p.ExtraStats {
+		mem, cpu := rp.usageSignals(ns, cluster, st)
+		mem.optional, cpu.optional = true, true
+		if st != p.MemStat {
+			sigs = append(sigs, mem)
+		}
+		if st != p.CPUStat {
+			sigs = append(sigs, cpu)
+		}
+	}
+
+	signals, fetched, err := s.fetchSignals(ctx, sigs, rp.sem, p.QueryTimeout)
+	if err != nil {
+		return nil, fetched, err
+	}
 
 	// ---------------------------------------------------------------------
-	// 2. Index all signals by (namespace|cluster|container)
+	// 2. Index all signals by identity labels (namespace, container, cluster, extras)
 	// ---------------------------------------------------------------------
 
-	key := func(m map[string]string) string {
-		return m["namespace"] + "|" + m["uw_cluster"] + "|" + m["container"]
+	key := keyBy(labels.Identity())
+
+	// Usage at the sizing statistic: usage/request ratios, or absolute
+	// usage in absolute mode (turned into ratios per result below)
+	idMap := map[string]vm.Metric{}
+	memUsageMap := map[string]float64{}
+	for _, s := range signals[memSig.name] {
+		memUsageMap[key(s.Metric)] = s.Value.Value
+		if abs {
+			idMap[key(s.Metric)] = s.Metric
+		}
 	}
 
-	memP95Map := map[string]float64{}
-	for _, s := range memP95 {
-		memP95Map[key(s.Metric)] = s.ValueFloat
+	cpuUsageMap := map[string]float64{}
+	for _, s := range signals[cpuSig.name] {
+		cpuUsageMap[key(s.Metric)] = s.Value.Value
 	}
 
-	cpuP95Map := map[string]float64{}
-	for _, s := range cpuP95 {
-		cpuP95Map[key(s.Metric)] = s.ValueFloat
+	// Extra statistics per key, by statistic name
+	memStatsMap := map[string]map[string]float64{}
+	cpuStatsMap := map[string]map[string]float64{}
+	for _, st := range p.ExtraStats {
+		mem, cpu := rp.usageSignals(ns, cluster, st)
+		for _, s := range signals[mem.name] {
+			addStat(memStatsMap, key(s.Metric), st, s.Value.Value)
+		}
+		for _, s := range signals[cpu.name] {
+			addStat(cpuStatsMap, key(s.Metric), st, s.Value.Value)
+		}
 	}
 
 	memReqMap := map[string]float64{}
-	for _, s := range memReq {
-		memReqMap[key(s.Metric)] = s.ValueFloat
+	for _, s := range signals[sigMemReq] {
+		memReqMap[key(s.Metric)] = s.Value.Value
+		idMap[key(s.Metric)] = s.Metric
 	}
 
 	cpuReqMap := map[string]float64{}
-	for _, s := range cpuReq {
-		cpuReqMap[key(s.Metric)] = s.ValueFloat
+	for _, s := range signals[sigCPUReq] {
+		cpuReqMap[key(s.Metric)] = s.Value.Value
 	}
 
 	oomMap := map[string]bool{}
-	for _, s := range oom {
-		oomMap[key(s.Metric)] = s.ValueFloat >= 1
+	for _, s := range signals[sigOOM] {
+		oomMap[key(s.Metric)] = s.Value.Value >= 1
 	}
 
 	cpuThrottleMap := map[string]bool{}
-	for _, s := range cpuThrottle {
-		cpuThrottleMap[key(s.Metric)] = s.ValueFloat > 0
+	for _, s := range signals[sigCPUThrottle] {
+		cpuThrottleMap[key(s.Metric)] = s.Value.Value > 0
 	}
 
 	jvmHeapAfterGCMap := map[string]float64{}
-	for _, s := range jvmHeapAfterGC {
-		jvmHeapAfterGCMap[key(s.Metric)] = s.ValueFloat
+	for _, s := range signals[sigJVMHeapAfterGC] {
+		jvmHeapAfterGCMap[key(s.Metric)] = s.Value.Value
 	}
 
 	jvmNonHeapMap := map[string]int64{}
-	for _, s := range jvmNonHeap {
-		jvmNonHeapMap[key(s.Metric)] = int64(s.ValueFloat)
+	for _, s := range signals[sigJVMNonHeap] {
+		jvmNonHeapMap[key(s.Metric)] = int64(s.Value.Value)
+	}
+
+	// Init containers: their own requests and peak usage. A key with either
+	// request is an init container, even if it also shows up as a regular one.
+	initKeys := map[string]bool{}
+	initMemReqMap := map[string]float64{}
+	for _, s := range signals[sigInitMemReq] {
+		initMemReqMap[key(s.Metric)] = s.Value.Value
+		initKeys[key(s.Metric)] = true
+		idMap[key(s.Metric)] = s.Metric
+	}
+
+	initCPUReqMap := map[string]float64{}
+	for _, s := range signals[sigInitCPUReq] {
+		initCPUReqMap[key(s.Metric)] = s.Value.Value
+		initKeys[key(s.Metric)] = true
+		idMap[key(s.Metric)] = s.Metric
+	}
+
+	initMemPeakMap := map[string]float64{}
+	for _, s := range signals[sigInitMemPeak] {
+		initMemPeakMap[key(s.Metric)] = s.Value.Value
+	}
+
+	initCPUPeakMap := map[string]float64{}
+	for _, s := range signals[sigInitCPUPeak] {
+		initCPUPeakMap[key(s.Metric)] = s.Value.Value
+	}
+
+	memLimitMap := map[string]float64{}
+	for _, s := range signals[sigMemLimits] {
+		memLimitMap[key(s.Metric)] = s.Value.Value
+	}
+
+	cpuLimitMap := map[string]float64{}
+	for _, s := range signals[sigCPULimits] {
+		cpuLimitMap[key(s.Metric)] = s.Value.Value
+	}
+
+	memPeakMap := map[string]float64{}
+	for _, s := range signals[sigMemPeak] {
+		memPeakMap[key(s.Metric)] = s.Value.Value
+	}
+
+	cpuPeakMap := map[string]float64{}
+	for _, s := range signals[sigCPUPeak] {
+		cpuPeakMap[key(s.Metric)] = s.Value.Value
+	}
+
+	// Replica counts are keyed by workload when results are
+	replicaKey := keyBy(labels.ReplicaLabels())
+	replicasMap := map[string]float64{}
+	for _, s := range signals[sigReplicas] {
+		replicasMap[replicaKey(s.Metric)] = s.Value.Value
+	}
+
+	replicasAvgMap := map[string]float64{}
+	for _, s := range signals[sigReplicasAvg] {
+		replicasAvgMap[replicaKey(s.Metric)] = s.Value.Value
+	}
+
+	replicasPeakMap := map[string]float64{}
+	for _, s := range signals[sigReplicasPeak] {
+		replicasPeakMap[replicaKey(s.Metric)] = s.Value.Value
+	}
+
+	// Confidence evidence; a failed signal leaves its map nil (unknown).
+	// Without a series, coverage and restarts are 0 and the pod age unknown.
+	var coverageMap, podAgeMap, restartsMap map[string]float64
+	if samples, ok := signals[sigCoverage]; ok {
+		coverageMap = map[string]float64{}
+		for _, s := range samples {
+			coverageMap[key(s.Metric)] = s.Value.Value
+		}
+	}
+	if samples, ok := signals[sigPodAge]; ok {
+		podAgeMap = map[string]float64{}
+		for _, s := range samples {
+			podAgeMap[key(s.Metric)] = s.Value.Value
+		}
+	}
+	if samples, ok := signals[sigRestarts]; ok {
+		restartsMap = map[string]float64{}
+		for _, s := range samples {
+			restartsMap[key(s.Metric)] = s.Value.Value
+		}
+	}
+
+	for k := range initKeys {
+		memPeakMap[k] = initMemPeakMap[k]
+		cpuPeakMap[k] = initCPUPeakMap[k]
+		memReqMap[k] = initMemReqMap[k]
+		cpuReqMap[k] = initCPUReqMap[k]
+		memUsageMap[k] = ratio(initMemPeakMap[k], initMemReqMap[k])
+		cpuUsageMap[k] = ratio(initCPUPeakMap[k], initCPUReqMap[k])
+		if abs {
+			memUsageMap[k], cpuUsageMap[k] = initMemPeakMap[k], initCPUPeakMap[k]
+		}
+		delete(memStatsMap, k)
+		delete(cpuStatsMap, k)
+	}
+
+	// Absolute mode also sizes containers that have no memory request
+	if abs {
+		for k := range memUsageMap {
+			if _, ok := memReqMap[k]; !ok {
+				memReqMap[k] = 0
+			}
+		}
 	}
 
 	// ---------------------------------------------------------------------
@@ -155,25 +657,53 @@ func (s *RightsizeService) Run(
 	results := make([]model.RightsizeResult, 0, len(memReqMap))
 
 	for k, memReqBytesF := range memReqMap {
-		parts := split3(k)
-		ns, cl, container := parts[0], parts[1], parts[2]
+		id := idMap[k]
 
 		memReqBytes := int64(memReqBytesF)
 		cpuReqCores := cpuReqMap[k]
 
-		memRatio := memP95Map[k]
-		cpuRatio := cpuP95Map[k]
+		memRatio := memUsageMap[k]
+		cpuRatio := cpuUsageMap[k]
+		var memUsage, cpuUsage float64
+		if abs {
+			memUsage, cpuUsage = memRatio, cpuRatio
+			memRatio, cpuRatio = ratio(memUsage, memReqBytesF), ratio(cpuUsage, cpuReqCores)
+		}
+
+		role := model.RoleApp
+		switch {
+		case initKeys[k]:
+			role = model.RoleInit
+		case rp.sidecars != nil && rp.sidecars.MatchString(id["container"]):
+			role = model.RoleSidecar
+		}
 
 		r := model.RightsizeResult{
-			Namespace: ns,
-			Cluster:   cl,
-			Container: container,
+			Namespace: id["namespace"],
+			Cluster:   id[labels.Cluster],
+			Container: id["container"],
+			Role:      role,
 
-			MemP95Ratio: memRatio,
-			CpuP95Ratio: cpuRatio,
+			WorkloadKind: id[promql.WorkloadKindLabel],
+			Workload:     id[promql.WorkloadLabel],
+
+			Labels: extraLabels(id, labels),
+
+			MemUsageRatio: memRatio,
+			CpuUsageRatio: cpuRatio,
+			MemUsageBytes: int64(memUsage),
+			CpuUsageCores: cpuUsage,
 
 			MemRequestBytes: memReqBytes,
 			CpuRequestCores: cpuReqCores,
+			Replicas:        replicasMap[replicaKey(id)],
+			ReplicasAvg:     replicasAvgMap[replicaKey(id)],
+			ReplicasPeak:    replicasPeakMap[replicaKey(id)],
+
+			MemLimitBytes: int64(memLimitMap[k]),
+			CpuLimitCores: cpuLimitMap[k],
+			MemPeakBytes:  int64(memPeakMap[k]),
+			CpuPeakCores:  cpuPeakMap[k],
 
 			OOMKilled:    oomMap[k],
 			CPUThrottled: cpuThrottleMap[k],
@@ -182,66 +712,163 @@ func (s *RightsizeService) Run(
 			JVMNonHeapBytes:     jvmNonHeapMap[k],
 		}
 
+		// Init containers run briefly: no extra statistics, and window
+		// coverage says nothing about them
+		var evidence decision.Evidence
+		if role != model.RoleInit {
+			memStats, cpuStats := memStatsMap[k], cpuStatsMap[k]
+			if abs {
+				memStats, cpuStats = ratios(memStats, memReqBytesF), ratios(cpuStats, cpuReqCores)
+			}
+			r.MemUsageStats = withSizingStat(memStats, p.ExtraStats, p.MemStat, memRatio)
+			r.CpuUsageStats = withSizingStat(cpuStats, p.ExtraStats, p.CPUStat, cpuRatio)
+
+			evidence = decision.Evidence{
+				Coverage:    lookup(coverageMap, k, true),
+				PodAgeHours: lookup(podAgeMap, k, false),
+				Restarts:    lookup(restartsMap, k, true),
+			}
+			r.Coverage, r.PodAgeHours, r.Restarts = coverageMap[k], podAgeMap[k], restartsMap[k]
+		}
+
 		// -----------------------------------------------------------------
 		// 4. Recommendation math (NO decisions here)
 		// -----------------------------------------------------------------
 
-		if r.OOMKilled {
+		// Sidecars get extra headroom; init containers are sized to peak
+		target, safety := p.TargetUtil, p.SafetyFactor
+		switch role {
+		case model.RoleSidecar:
+			if p.SidecarSafety > 0 {
+				safety = p.SidecarSafety
+			}
+		case model.RoleInit:
+			target = 1
+		}
+
+		switch {
+		case r.OOMKilled:
 			r.MemRecommendedBytes = memReqBytes
 			r.CpuRecommendedCores = cpuReqCores
-		} else {
+		case abs:
+			r.MemRecommendedBytes = recommendMemFromUsage(memReqBytes, memUsage, target, safety, p.MemRoundMiB)
+			r.CpuRecommendedCores = recommendCPUFromUsage(cpuReqCores, cpuUsage, target, safety, p.CPURoundm)
+		default:
 			r.MemRecommendedBytes = recommendMem(
 				memReqBytes,
 				memRatio,
-				p.TargetUtil,
-				p.SafetyFactor,
+				target,
+				safety,
 				p.MemRoundMiB,
 			)
 
 			r.CpuRecommendedCores = recommendCPU(
 				cpuReqCores,
 				cpuRatio,
-				p.TargetUtil,
-				p.SafetyFactor,
+				target,
+				safety,
 				p.CPURoundm,
 			)
 		}
 
+		// Guardrails bound the step size and the absolute size
+		var memClamp, cpuClamp string
+		r.MemRecommendedBytes, memClamp = rp.guardrails.clampMem(memReqBytes, r.MemRecommendedBytes, p.MemRoundMiB)
+		r.CpuRecommendedCores, cpuClamp = rp.guardrails.clampCPU(cpuReqCores, r.CpuRecommendedCores, p.CPURoundm)
+
+		r.MemDeltaBytes = r.MemRecommendedBytes - memReqBytes
+		r.CpuDeltaCores = r.CpuRecommendedCores - cpuReqCores
+
 		// -----------------------------------------------------------------
 		// 5. Decisions (pure policy layer)
 		// -----------------------------------------------------------------
 
-		r.MemoryDecision, r.MemoryWhy = decision.DecideMemory(memRatio, r.OOMKilled)
-		r.CPUDecision, r.CPUWhy = decision.DecideCPU(cpuRatio, r.CPUThrottled)
-		
+		th, overrides := rp.policy.For(r.Namespace, r.Workload, r.Container)
+		r.PolicyOverrides = overrides
+
+		if role == model.RoleInit {
+			r.MemoryDecision, r.MemoryWhy = decision.DecideInitMemory(memRatio, r.OOMKilled, th.Memory)
+			r.CPUDecision, r.CPUWhy = decision.DecideInitCPU(cpuRatio, th.CPU)
+			// An init container may request only one resource
+			if memReqBytes <= 0 && !r.OOMKilled {
+				r.MemoryDecision, r.MemoryWhy = model.MemKeep, "no init memory request (nothing to resize)"
+			}
+			if cpuReqCores <= 0 {
+				r.CPUDecision, r.CPUWhy = model.CPUKeep, "no init cpu request (nothing to resize)"
+			}
+			// Sized to peak, the recommendation may differ even on KEEP
+			if r.MemoryDecision == model.MemKeep {
+				r.MemRecommendedBytes, r.MemDeltaBytes = memReqBytes, 0
+			}
+			if r.CPUDecision == model.CPUKeep {
+				r.CpuRecommendedCores, r.CpuDeltaCores = cpuReqCores, 0
+			}
+		} else {
+			r.MemoryDecision, r.MemoryWhy = decision.DecideMemory(string(p.MemStat), memRatio, r.OOMKilled, th.Memory)
+			r.CPUDecision, r.CPUWhy = decision.DecideCPU(string(p.CPUStat), cpuRatio, r.CPUThrottled, th.CPU)
+
+			if abs && memReqBytes <= 0 && memUsage > 0 && !r.OOMKilled {
+				r.MemoryDecision, r.MemoryWhy = decision.DecideUnsetMemory(string(p.MemStat), r.MemUsageBytes)
+			}
+			if abs && cpuReqCores <= 0 && cpuUsage > 0 && !r.CPUThrottled {
+				r.CPUDecision, r.CPUWhy = decision.DecideUnsetCPU(string(p.CPUStat), cpuUsage)
+			}
+		}
+
+		if role != model.RoleInit {
+			r.Confidence, r.ConfidenceWhy = decision.GradeConfidence(evidence, th.Confidence)
+			if p.LowConfidence == LowConfidenceKeep {
+				decision.KeepLowConfidence(&r)
+			}
+		}
+
 		r.JVMHeapDecision = decision.DecideJVMHeap(
 			r.JVMHeapAfterGCRatio,
+			th.JVMHeapAfterGC,
 		)
 
 		r.JVMNonHeapDecision = decision.DecideJVMNonHeap(
 			r.JVMNonHeapBytes,
 			r.MemRequestBytes,
+			th.JVMNonHeapRatio,
 		)
 
-		results = append(results, r)
-	}
+		// Custom policy rules get the last word
+		if err := rp.policy.Apply(&r); err != nil {
+			return nil, fetched, err
+		}
 
-	// ---------------------------------------------------------------------
-	// 6. Rank results
-	// ---------------------------------------------------------------------
+		// Only REDUCE and INCREASE change a request; everything after
+		// (limits, totals, savings) prices the final decisions
+		holdUnchanged(&r)
 
-	sort.Slice(results, func(i, j int) bool {
-		if p.Bottom {
-			return results[i].MemP95Ratio < results[j].MemP95Ratio
+		// Clamp notes only where the clamped value is still the recommendation
+		if memClamp != "" && changesRequest(string(r.MemoryDecision)) {
+			r.MemoryWhy += "; " + memClamp
+		}
+		if cpuClamp != "" && changesRequest(string(r.CPUDecision)) {
+			r.CPUWhy += "; " + cpuClamp
+		}
+
+		// Limits follow the final request recommendations, within the same bounds
+		recommendLimits(&r, p, safety, rp.guardrails)
+
+		// Totals and savings across every replica; init containers only
+		// run briefly, so their requests are not priced
+		replicas := replicaCount(r)
+		r.MemDeltaTotalBytes = int64(float64(r.MemDeltaBytes) * replicas)
+		r.CpuDeltaTotalCores = r.CpuDeltaCores * replicas
+		if p.Pricing != nil && role != model.RoleInit {
+			rate, tier := p.Pricing.For(r.Cluster, r.Namespace, r.Workload, r.Container)
+			r.PriceTier = tier
+			r.EstSavingsPerHourUSD = rate.Savings(r.CpuDeltaCores, r.MemDeltaBytes, replicas)
+			r.EstSavingsPerMonthUSD = r.EstSavingsPerHourUSD * pricing.HoursPerMonth
 		}
-		return results[i].MemP95Ratio > results[j].MemP95Ratio
-	})
 
-	if p.TopK > 0 && len(results) > p.TopK {
-		results = results[:p.TopK]
+		results = append(results, r)
 	}
 
-	return results, meta, nil
+	return results, fetched, nil
 }
 
 // -------------------------------------------------------------------------
@@ -251,12 +878,98 @@ func (s *RightsizeService) Run(
 func (s *RightsizeService) query(
 	ctx context.Context,
 	expr string,
-) ([]instantSample, error) {
-	raw, err := s.vm.Query(ctx, vm.QueryOptions{Expr: expr})
+) ([]vm.Sample, []string, error) {
+	samples, resp, err := s.ds.QueryVector(ctx, vm.QueryOptions{Expr: expr})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return parseInstantVector(raw)
+	return samples, resp.Warnings, nil
+}
+
+// keyBy joins the values of labels into a map key.
+func keyBy(labels []string) func(vm.Metric) string {
+	return func(m vm.Metric) string {
+		vals := make([]string, len(labels))
+		for i, l := range labels {
+			vals[i] = m[l]
+		}
+		return strings.Join(vals, "\x00")
+	}
+}
+
+// lookup returns m[k], or nil when m is unknown (nil). Missing keys count
+// as 0 when zeroIfMissing, otherwise as unknown.
+func lookup(m map[string]float64, k string, zeroIfMissing bool) *float64 {
+	if m == nil {
+		return nil
+	}
+	v, ok := m[k]
+	if !ok && !zeroIfMissing {
+		return nil
+	}
+	return &v
+}
+
+// holdUnchanged resets the recommendation to the current request when the
+// decision (KEEP, SKIP_*) leaves it as is.
+func holdUnchanged(r *model.RightsizeResult) {
+	switch r.MemoryDecision {
+	case model.MemKeep, model.MemSkipOOM, model.MemSkip:
+		r.MemRecommendedBytes, r.MemDeltaBytes = r.MemRequestBytes, 0
+	}
+	switch r.CPUDecision {
+	case model.CPUKeep, model.CPUSkipThrottling, model.CPUSkip:
+		r.CpuRecommendedCores, r.CpuDeltaCores = r.CpuRequestCores, 0
+	}
+}
+
+// changesRequest reports whether a decision moves the request.
+func changesRequest(d string) bool {
+	return d == "REDUCE" || d == "INCREASE"
+}
+
+// replicaCount is what totals and savings scale by: the average replica
+// count, else the current one, else a single pod.
+func replicaCount(r model.RightsizeResult) float64 {
+	switch {
+	case r.ReplicasAvg > 0:
+		return r.ReplicasAvg
+	case r.Replicas > 0:
+		return r.Replicas
+	default:
+		return 1
+	}
+}
+
+// usageSignals builds the memory and CPU usage signals of a statistic:
+// usage/request ratios, or absolute usage in absolute mode.
+func (rp runPlan) usageSignals(ns promql.NamespaceSelector, cluster string, st promql.Stat) (mem, cpu signal) {
+	q := rp.q
+	if rp.params.Mode == ModeAbsolute {
+		return signal{name: sigMemUsage(st), expr: q.MemUsage(ns, cluster, st, rp.window, rp.subStep)},
+			signal{name: sigCPUUsage(st), expr: q.CpuUsage(ns, cluster, st, rp.window, rp.subStep)}
+	}
+	return signal{name: sigMemRatio(st), expr: q.MemRatio(ns, cluster, st, rp.window, rp.subStep)},
+		signal{name: sigCPURatio(st), expr: q.CpuRatio(ns, cluster, st, rp.window, rp.subStep)}
+}
+
+func addStat(m map[string]map[string]float64, k string, st promql.Stat, v float64) {
+	if m[k] == nil {
+		m[k] = map[string]float64{}
+	}
+	m[k][string(st)] = v
+}
+
+// withSizingStat fills in an extra statistic that is also the sizing one,
+// which is fetched only once.
+func withSizingStat(stats map[string]float64, extra []promql.Stat, sizing promql.Stat, v float64) map[string]float64 {
+	if slices.Contains(extra, sizing) {
+		if stats == nil {
+			stats = map[string]float64{}
+		}
+		stats[string(sizing)] = v
+	}
+	return stats
 }
 
 func recommendMem(
@@ -287,17 +1000,85 @@ func recommendCPU(
 	return math.Ceil(reco/step) * step
 }
 
-func split3(s string) [3]string {
-	out := [3]string{"", "", ""}
-	cur := 0
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if s[i] == '|' && cur < 2 {
-			out[cur] = s[start:i]
-			cur++
-			start = i + 1
+// recommendMemFromUsage and recommendCPUFromUsage size from absolute usage
+// (usage / target * safety), keeping the current request without usage.
+func recommendMemFromUsage(
+	currentBytes int64,
+	usageBytes, target, safety float64,
+	roundMiB int64,
+) int64 {
+	if usageBytes <= 0 || target <= 0 {
+		return currentBytes
+	}
+	reco := usageBytes / target * safety
+	step := float64(roundMiB) * 1024 * 1024
+	return int64(math.Ceil(reco/step) * step)
+}
+
+func recommendCPUFromUsage(
+	currentCores float64,
+	usageCores, target, safety float64,
+	roundm int64,
+) float64 {
+	if usageCores <= 0 || target <= 0 {
+		return currentCores
+	}
+	reco := usageCores / target * safety
+	step := float64(roundm) / 1000.0
+	return math.Ceil(reco/step) * step
+}
+
+// ratios turns absolute usage statistics into usage/request ratios.
+func ratios(usage map[string]float64, request float64) map[string]float64 {
+	if usage == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(usage))
+	for st, v := range usage {
+		out[st] = ratio(v, request)
+	}
+	return out
+}
+
+// ratio is usage/request, 0 when either is unknown.
+func ratio(usage, request float64) float64 {
+	if usage <= 0 || request <= 0 {
+		return 0
+	}
+	return usage / request
+}
+
+// compileSidecars joins sidecar name patterns into one anchored regexp.
+func compileSidecars(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, pat := range patterns {
+		if err := promql.ValidateRegexp(pat); err != nil {
+			return nil, fmt.Errorf("sidecar pattern: %w", err)
 		}
 	}
-	out[cur] = s[start:]
+	return regexp.MustCompile("^(?:" + strings.Join(patterns, "|") + ")$"), nil
+}
+
+func withRole(results []model.RightsizeResult, role model.ContainerRole) []model.RightsizeResult {
+	var out []model.RightsizeResult
+	for _, r := range results {
+		if r.Role == role {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// extraLabels returns the schema's extra identity labels of a series.
+func extraLabels(m vm.Metric, labels promql.LabelSchema) map[string]string {
+	if len(labels.Extra) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels.Extra))
+	for _, n := range labels.Extra {
+		out[n] = m[n]
+	}
 	return out
 }
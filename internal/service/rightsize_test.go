@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/model"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/promql"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm/vmtest"
+)
+
+const (
+	testNamespace = "shop"
+	testCluster   = "c1"
+	gib           = 1 << 30
+)
+
+var testQueries = promql.NewRightsizeQueries(promql.LabelSchema{}.WithDefaults())
+
+func testParams() RightsizeParams {
+	return RightsizeParams{
+		Namespaces:   promql.Namespace(testNamespace),
+		Clusters:     []string{testCluster},
+		Window:       "7d",
+		SubqueryStep: "5m",
+		OOMWindow:    "7d",
+		TargetUtil:   0.7,
+		SafetyFactor: 1.1,
+		MemRoundMiB:  16,
+		CPURoundm:    10,
+	}
+}
+
+// sample is one "api" container series in the test namespace and cluster.
+func sample(v float64) vm.Sample {
+	return vmtest.Sample(v, "namespace", testNamespace, "container", "api", "uw_cluster", testCluster)
+}
+
+// newTestServer serves an "api" container requesting 1GiB and 1 core that
+// uses 30% of both over a fully covered window. mutate may register routes
+// first, which win over these.
+func newTestServer(t *testing.T, mutate func(s *vmtest.Server)) *vmtest.Server {
+	t.Helper()
+
+	p := testParams()
+	ns := p.Namespaces
+	window, subStep := promql.MustDuration(p.Window), promql.MustDuration(p.SubqueryStep)
+
+	srv := vmtest.NewServer(t)
+	if mutate != nil {
+		mutate(srv)
+	}
+	srv.HandleExpr(testQueries.MemRequests(ns, testCluster), vmtest.Vector(sample(gib)))
+	srv.HandleExpr(testQueries.CpuRequests(ns, testCluster), vmtest.Vector(sample(1)))
+	srv.HandleExpr(testQueries.MemRatio(ns, testCluster, promql.StatP95, window, subStep), vmtest.Vector(sample(0.3)))
+	srv.HandleExpr(testQueries.CpuRatio(ns, testCluster, promql.StatP95, window, subStep), vmtest.Vector(sample(0.3)))
+	srv.HandleExpr(testQueries.Coverage(ns, testCluster, window, subStep), vmtest.Vector(sample(1)))
+	srv.HandleExpr(testQueries.PodAge(ns, testCluster), vmtest.Vector(sample(240)))
+	return srv
+}
+
+func runOne(t *testing.T, c *vm.Client) (model.RightsizeResult, model.RightsizeMeta) {
+	t.Helper()
+
+	results, meta, err := NewRightsizeService(c).Run(context.Background(), testParams())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	return results[0], meta
+}
+
+func TestRunReducesUnderusedContainer(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	r, _ := runOne(t, srv.Client(t, vm.Config{}))
+	if r.MemoryDecision != model.MemReduce {
+		t.Errorf("memory decision = %s, want %s", r.MemoryDecision, model.MemReduce)
+	}
+	if r.MemRecommendedBytes >= gib || r.MemDeltaBytes >= 0 {
+		t.Errorf("memory recommendation = %d (delta %d), want below 1GiB", r.MemRecommendedBytes, r.MemDeltaBytes)
+	}
+}
+
+func TestRunSkipsOOMKilled(t *testing.T) {
+	p := testParams()
+	oom := testQueries.OOMKilled(p.Namespaces, testCluster, promql.MustDuration(p.OOMWindow))
+	srv := newTestServer(t, func(s *vmtest.Server) {
+		s.HandleExpr(oom, vmtest.Vector(sample(1)))
+	})
+
+	r, _ := runOne(t, srv.Client(t, vm.Config{}))
+	if !r.OOMKilled || r.MemoryDecision != model.MemSkipOOM {
+		t.Fatalf("memory decision = %s (oom %v), want %s", r.MemoryDecision, r.OOMKilled, model.MemSkipOOM)
+	}
+	if r.MemRecommendedBytes != gib || r.MemDeltaBytes != 0 {
+		t.Errorf("memory recommendation = %d (delta %d), want the current request", r.MemRecommendedBytes, r.MemDeltaBytes)
+	}
+}
+
+func TestRunPartialResponse(t *testing.T) {
+	memReq := testQueries.MemRequests(testParams().Namespaces, testCluster)
+	partial := func(s *vmtest.Server) {
+		s.HandleExpr(memReq, vmtest.Vector(sample(gib)).Partial())
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		srv := newTestServer(t, partial)
+		_, _, err := NewRightsizeService(srv.Client(t, vm.Config{})).Run(context.Background(), testParams())
+		if !errors.Is(err, vm.ErrPartialResponse) {
+			t.Fatalf("Run error = %v, want %v", err, vm.ErrPartialResponse)
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		srv := newTestServer(t, partial)
+		_, meta := runOne(t, srv.Client(t, vm.Config{Partial: vm.PartialWarn}))
+		if !slices.ContainsFunc(meta.Warnings, func(w string) bool { return strings.Contains(w, "partial response") }) {
+			t.Errorf("warnings = %q, want a partial response warning", meta.Warnings)
+		}
+	})
+}
+
+func TestRunRetriesAfterRetryAfter(t *testing.T) {
+	memReq := testQueries.MemRequests(testParams().Namespaces, testCluster)
+	var calls atomic.Int32
+	srv := newTestServer(t, func(s *vmtest.Server) {
+		s.HandleFunc(vmtest.Exact(memReq), func(vmtest.Request) vmtest.Reply {
+			if calls.Add(1) == 1 {
+				// Far beyond RetryMaxDelay: the client must cap it
+				reply := vmtest.Fail(http.StatusServiceUnavailable, "unavailable", "try later")
+				reply.Header = http.Header{"Retry-After": {"3600"}}
+				return reply
+			}
+			return vmtest.Vector(sample(gib))
+		})
+	})
+
+	start := time.Now()
+	r, _ := runOne(t, srv.Client(t, vm.Config{MaxRetries: 2, RetryMaxDelay: 50 * time.Millisecond}))
+	if got := calls.Load(); got != 2 {
+		t.Errorf("memory requests queried %d times, want 2", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %s, Retry-After was not capped", elapsed)
+	}
+	if r.MemRequestBytes != gib {
+		t.Errorf("memory request = %d, want %d", r.MemRequestBytes, gib)
+	}
+}
+
+func TestRunReportsInitContainerWithOnlyCPURequest(t *testing.T) {
+	p := testParams()
+	window, subStep := promql.MustDuration(p.Window), promql.MustDuration(p.SubqueryStep)
+	migrate := func(v float64) vm.Sample {
+		return vmtest.Sample(v, "namespace", testNamespace, "container", "migrate", "uw_cluster", testCluster)
+	}
+	srv := newTestServer(t, func(s *vmtest.Server) {
+		s.HandleExpr(testQueries.InitCpuRequests(p.Namespaces, testCluster), vmtest.Vector(migrate(1)))
+		s.HandleExpr(testQueries.InitCpuPeak(p.Namespaces, testCluster, window, subStep), vmtest.Vector(migrate(0.2)))
+	})
+
+	results, _, err := NewRightsizeService(srv.Client(t, vm.Config{})).Run(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	i := slices.IndexFunc(results, func(r model.RightsizeResult) bool { return r.Container == "migrate" })
+	if i < 0 {
+		t.Fatalf("init container without a memory request was dropped (got %d results)", len(results))
+	}
+
+	r := results[i]
+	if r.Role != model.RoleInit {
+		t.Errorf("role = %s, want %s", r.Role, model.RoleInit)
+	}
+	if r.MemoryDecision != model.MemKeep || !strings.Contains(r.MemoryWhy, "no init memory request") {
+		t.Errorf("memory decision = %s (%q), want KEEP for the missing request", r.MemoryDecision, r.MemoryWhy)
+	}
+	if r.MemRecommendedBytes != 0 || r.MemDeltaBytes != 0 {
+		t.Errorf("memory recommendation = %d (delta %d), want none", r.MemRecommendedBytes, r.MemDeltaBytes)
+	}
+	if r.CPUDecision != model.CPUReduce || r.CpuRecommendedCores >= 1 {
+		t.Errorf("cpu decision = %s (%v cores), want a reduction sized to the peak", r.CPUDecision, r.CpuRecommendedCores)
+	}
+}
+
+func TestRunAbsoluteMode(t *testing.T) {
+	p := testParams()
+	p.Mode = ModeAbsolute
+	window, subStep := promql.MustDuration(p.Window), promql.MustDuration(p.SubqueryStep)
+	usage := func(s *vmtest.Server) {
+		s.HandleExpr(testQueries.MemUsage(p.Namespaces, testCluster, promql.StatP95, window, subStep), vmtest.Vector(sample(300<<20)))
+		s.HandleExpr(testQueries.CpuUsage(p.Namespaces, testCluster, promql.StatP95, window, subStep), vmtest.Vector(sample(0.3)))
+	}
+
+	t.Run("sized from usage", func(t *testing.T) {
+		srv := newTestServer(t, usage)
+		results, _, err := NewRightsizeService(srv.Client(t, vm.Config{})).Run(context.Background(), p)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("Run = %d results, %v", len(results), err)
+		}
+		// 300Mi / 0.7 * 1.1 rounds up to 480Mi whatever the request
+		r := results[0]
+		if r.MemoryDecision != model.MemReduce || r.MemRecommendedBytes != 480<<20 {
+			t.Errorf("memory = %s %dMi, want REDUCE to 480Mi", r.MemoryDecision, r.MemRecommendedBytes>>20)
+		}
+		if r.MemUsageBytes != 300<<20 || r.MemUsageRatio != float64(300<<20)/gib {
+			t.Errorf("usage = %d bytes (ratio %v)", r.MemUsageBytes, r.MemUsageRatio)
+		}
+	})
+
+	t.Run("no requests", func(t *testing.T) {
+		srv := newTestServer(t, func(s *vmtest.Server) {
+			usage(s)
+			s.HandleExpr(testQueries.MemRequests(p.Namespaces, testCluster), vmtest.Vector())
+			s.HandleExpr(testQueries.CpuRequests(p.Namespaces, testCluster), vmtest.Vector())
+		})
+		results, _, err := NewRightsizeService(srv.Client(t, vm.Config{})).Run(context.Background(), p)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("Run = %d results, %v", len(results), err)
+		}
+		r := results[0]
+		if r.MemoryDecision != model.MemIncrease || !strings.Contains(r.MemoryWhy, "no memory request") || r.MemRecommendedBytes != 480<<20 {
+			t.Errorf("memory = %s %dMi (%q), want INCREASE to 480Mi", r.MemoryDecision, r.MemRecommendedBytes>>20, r.MemoryWhy)
+		}
+		if r.CPUDecision != model.CPUIncrease || !strings.Contains(r.CPUWhy, "no cpu request") || r.CpuRecommendedCores <= 0.3 {
+			t.Errorf("cpu = %s %v (%q), want an increase above usage", r.CPUDecision, r.CpuRecommendedCores, r.CPUWhy)
+		}
+	})
+}
+
+func TestRunGradesConfidence(t *testing.T) {
+	p := testParams()
+	window, subStep := promql.MustDuration(p.Window), promql.MustDuration(p.SubqueryStep)
+	coverage := testQueries.Coverage(p.Namespaces, testCluster, window, subStep)
+
+	tests := []struct {
+		name  string
+		reply vmtest.Reply
+		want  model.Confidence
+		why   string
+	}{
+		{name: "full coverage", reply: vmtest.Vector(sample(1)), want: model.ConfidenceHigh},
+		{name: "no coverage series", reply: vmtest.Vector(), want: model.ConfidenceLow, why: "coverage 0% < 50%"},
+		{name: "coverage query failed", reply: vmtest.Fail(http.StatusBadRequest, "bad_data", "unsupported"), want: model.ConfidenceMedium, why: "no coverage data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, func(s *vmtest.Server) {
+				s.HandleExpr(coverage, tt.reply)
+			})
+			r, _ := runOne(t, srv.Client(t, vm.Config{}))
+			if r.Confidence != tt.want || r.ConfidenceWhy != tt.why {
+				t.Errorf("confidence = %s %q, want %s %q", r.Confidence, r.ConfidenceWhy, tt.want, tt.why)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Backend names a Prometheus-compatible query API flavor.
+type Backend string
+
+const (
+	BackendVMCluster  Backend = "vm-cluster"
+	BackendVMSingle   Backend = "vm-single"
+	BackendPrometheus Backend = "prometheus"
+	BackendThanos     Backend = "thanos"
+	BackendMimir      Backend = "mimir"
+)
+
+var Backends = []Backend{
+	BackendVMCluster,
+	BackendVMSingle,
+	BackendPrometheus,
+	BackendThanos,
+	BackendMimir,
+}
+
+// flavor captures what differs between backends: where the Prometheus API
+// lives, how health is checked, and per-request decoration (tenancy, params).
+type flavor interface {
+	apiPrefix() string
+	healthPath() string
+	decorate(h http.Header, params url.Values)
+}
+
+func newFlavor(b Backend, tenant string, partial PartialPolicy) (flavor, error) {
+	switch b {
+	case "", BackendVMCluster:
+		t, err := ParseTenant(tenant)
+		if err != nil {
+			return nil, err
+		}
+		return vmCluster{tenant: t}, nil
+	case BackendVMSingle:
+		return vmSingle{}, nil
+	case BackendPrometheus:
+		return prometheus{}, nil
+	case BackendThanos:
+		return thanos{partial: partial != PartialReject}, nil
+	case BackendMimir:
+		return mimir{orgID: tenant}, nil
+	}
+	return nil, fmt.Errorf("unknown backend %q (want one of %v)", b, Backends)
+}
+
+// VictoriaMetrics cluster: vmselect serves /select/<accountID[:projectID]>/prometheus.
+type vmCluster struct{ tenant string }
+
+func (f vmCluster) apiPrefix() string              { return "/select/" + f.tenant + "/prometheus" }
+func (vmCluster) healthPath() string               { return "/-/healthy" }
+func (vmCluster) decorate(http.Header, url.Values) {}
+
+// VictoriaMetrics single-node serves the API at the root.
+type vmSingle struct{}
+
+func (vmSingle) apiPrefix() string                { return "" }
+func (vmSingle) healthPath() string               { return "/health" }
+func (vmSingle) decorate(http.Header, url.Values) {}
+
+type prometheus struct{}
+
+func (prometheus) apiPrefix() string                { return "" }
+func (prometheus) healthPath() string               { return "/-/healthy" }
+func (prometheus) decorate(http.Header, url.Values) {}
+
+// Thanos Query: deduplicate replicas and make partial responses explicit.
+type thanos struct{ partial bool }
+
+func (thanos) apiPrefix() string  { return "" }
+func (thanos) healthPath() string { return "/-/healthy" }
+func (f thanos) decorate(_ http.Header, params url.Values) {
+	params.Set("dedup", "true")
+	params.Set("partial_response", fmt.Sprintf("%t", f.partial))
+}
+
+// Grafana Mimir: API under /prometheus, tenant via X-Scope-OrgID.
+type mimir struct{ orgID string }
+
+func (mimir) apiPrefix() string  { return "/prometheus" }
+func (mimir) healthPath() string { return "/ready" }
+func (f mimir) decorate(h http.Header, _ url.Values) {
+	if f.orgID != "" {
+		h.Set("X-Scope-OrgID", f.orgID)
+	}
+}
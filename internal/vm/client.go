@@ -2,25 +2,176 @@ package vm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+// DefaultBaseURL is the vmselect used when no URL is given. It only applies
+// to the vm-cluster backend; every other backend needs an explicit URL.
+const DefaultBaseURL = "http://vmselect.management.prod.internal:8481"
+
+// Config describes how to reach a Prometheus-compatible query endpoint.
+type Config struct {
+	// Backend selects the API flavor (default: vm-cluster).
+	Backend Backend
+
+	// BaseURL is the server root (scheme://host:port[/prefix]).
+	BaseURL string
+
+	// Tenant is "accountID" or "accountID:projectID" for vm-cluster
+	// (empty means "0") and the X-Scope-OrgID for mimir. Ignored otherwise.
+	Tenant string
+
+	// Auth (all optional). BearerToken wins over basic auth.
+	BearerToken string
+	Username    string
+	Password    string
+
+	// Extra headers sent with every request.
+	Headers map[string]string
+
+	Timeout time.Duration
+
+	// Retries for 429/502/503/504 and connection resets (0 = no retries).
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration // also caps a server's Retry-After
+
+	// What to do with isPartial=true responses (default: reject).
+	Partial PartialPolicy
+}
+
+// PartialPolicy controls how VictoriaMetrics partial responses are treated.
+type PartialPolicy string
+
+const (
+	PartialReject PartialPolicy = "reject"
+	PartialWarn   PartialPolicy = "warn"
+	PartialAccept PartialPolicy = "accept"
+)
+
+func ParsePartialPolicy(s string) (PartialPolicy, error) {
+	switch p := PartialPolicy(s); p {
+	case "":
+		return PartialReject, nil
+	case PartialReject, PartialWarn, PartialAccept:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid partial policy %q (want reject|warn|accept)", s)
+}
+
+// ErrPartialResponse is returned for isPartial responses under PartialReject.
+var ErrPartialResponse = errors.New("query returned a partial response (some storage nodes were unavailable)")
+
+// Client is the HTTP Datasource shared by all backends.
 type Client struct {
+	Backend    Backend
 	BaseURL    string
+	Tenant     string
 	HTTPClient *http.Client
+
+	flavor flavor
+
+	bearerToken string
+	username    string
+	password    string
+	headers     map[string]string
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	partial        PartialPolicy
 }
 
-func NewClient(baseURL string) *Client {
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Backend == "" {
+		cfg.Backend = BackendVMCluster
+	}
+	if cfg.BaseURL == "" {
+		if cfg.Backend != BackendVMCluster {
+			return nil, fmt.Errorf("backend %s requires a base url", cfg.Backend)
+		}
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if _, err := url.Parse(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+
+	partial, err := ParsePartialPolicy(string(cfg.Partial))
+	if err != nil {
+		return nil, err
+	}
+
+	fl, err := newFlavor(cfg.Backend, cfg.Tenant, partial)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := cfg.Tenant
+	if f, ok := fl.(vmCluster); ok {
+		tenant = f.tenant
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = defaultRetryBaseDelay
+	}
+	if cfg.RetryMaxDelay <= 0 {
+		cfg.RetryMaxDelay = defaultRetryMaxDelay
+	}
+
 	return &Client{
-		BaseURL: baseURL,
+		Backend: cfg.Backend,
+		BaseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		Tenant:  tenant,
 		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: timeout,
 		},
+		flavor: fl,
+
+		bearerToken: cfg.BearerToken,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		headers:     cfg.Headers,
+
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: cfg.RetryBaseDelay,
+		retryMaxDelay:  cfg.RetryMaxDelay,
+		partial:        partial,
+	}, nil
+}
+
+// ParseTenant validates a VictoriaMetrics cluster tenant ("0", "42" or "42:7").
+func ParseTenant(s string) (string, error) {
+	if s == "" {
+		return "0", nil
 	}
+
+	account, project, hasProject := strings.Cut(s, ":")
+	if !isDigits(account) || (hasProject && !isDigits(project)) {
+		return "", fmt.Errorf("invalid tenant %q (want accountID or accountID:projectID)", s)
+	}
+	return s, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 func (c *Client) doGET(ctx context.Context, path string, params map[string]string) ([]byte, error) {
@@ -29,32 +180,95 @@ func (c *Client) doGET(ctx context.Context, path string, params map[string]strin
 		return nil, fmt.Errorf("invalid base url: %w", err)
 	}
 
-	u.Path = path
+	u.Path = strings.TrimRight(u.Path, "/") + path
 	q := u.Query()
 	for k, v := range params {
 		q.Set(k, v)
 	}
+	header := http.Header{}
+	c.flavor.decorate(header, q)
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	for attempt := 0; ; attempt++ {
+		body, wait, err := c.doOnce(ctx, u.String(), header)
+		if err == nil {
+			return body, nil
+		}
+		if wait < 0 || attempt >= c.maxRetries {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = backoff(attempt, c.retryBaseDelay, c.retryMaxDelay)
+		}
+		// A server may ask for minutes; never wait longer than our own backoff would.
+		wait = min(wait, c.retryMaxDelay)
+		if serr := sleepCtx(ctx, wait); serr != nil {
+			return nil, err
+		}
+	}
+}
+
+// doOnce performs a single attempt. wait < 0 means the error is final;
+// wait > 0 is a server-requested delay (Retry-After, capped by doGET);
+// 0 means use backoff.
+func (c *Client) doOnce(ctx context.Context, rawURL string, header http.Header) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, -1, fmt.Errorf("create request: %w", err)
+	}
+	c.authorize(req)
+	for k, v := range header {
+		req.Header[k] = v
 	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
+		err = fmt.Errorf("http request failed: %w", err)
+		if retryableError(err) {
+			return nil, 0, err
+		}
+		return nil, -1, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+		err = fmt.Errorf("read response: %w", err)
+		if retryableError(err) {
+			return nil, 0, err
+		}
+		return nil, -1, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("vm returned %s: %s", resp.Status, string(body))
+		err := fmt.Errorf("%s returned %s: %s", c.Backend, resp.Status, string(body))
+		if !retryableStatus(resp.StatusCode) {
+			return nil, -1, err
+		}
+		if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok && d > 0 {
+			return nil, d, err
+		}
+		return nil, 0, err
 	}
 
-	return body, nil
+	return body, 0, nil
+}
+
+func (c *Client) authorize(req *http.Request) {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// Healthy checks the backend's health/readiness endpoint.
+func (c *Client) Healthy(ctx context.Context) error {
+	_, err := c.doGET(ctx, c.flavor.healthPath(), nil)
+	return err
 }
@@ -0,0 +1,175 @@
+package vm_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm/vmtest"
+)
+
+const testExpr = "up"
+
+// failing replies with reply to the first n queries, then with one sample.
+func failing(n int32, reply vmtest.Reply, calls *atomic.Int32) func(vmtest.Request) vmtest.Reply {
+	return func(vmtest.Request) vmtest.Reply {
+		if calls.Add(1) <= n {
+			return reply
+		}
+		return vmtest.Vector(vmtest.Sample(1, "job", "api"))
+	}
+}
+
+func retryAfter(reply vmtest.Reply, v string) vmtest.Reply {
+	reply.Header = http.Header{"Retry-After": {v}}
+	return reply
+}
+
+func TestClientRetries(t *testing.T) {
+	unavailable := vmtest.Fail(http.StatusServiceUnavailable, "unavailable", "try later")
+
+	tests := []struct {
+		name      string
+		reply     vmtest.Reply
+		failures  int32
+		retries   int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "succeeds after retries", reply: unavailable, failures: 2, retries: 2, wantCalls: 3},
+		{name: "gives up after max retries", reply: unavailable, failures: 5, retries: 2, wantCalls: 3, wantErr: true},
+		{name: "no retries configured", reply: unavailable, failures: 1, retries: 0, wantCalls: 1, wantErr: true},
+		{name: "429 is retried", reply: vmtest.Fail(http.StatusTooManyRequests, "too_many", "slow down"), failures: 1, retries: 1, wantCalls: 2},
+		{name: "400 is final", reply: vmtest.Fail(http.StatusBadRequest, "bad_data", "parse error"), failures: 1, retries: 3, wantCalls: 1, wantErr: true},
+		{name: "Retry-After seconds is capped", reply: retryAfter(unavailable, "3600"), failures: 1, retries: 1, wantCalls: 2},
+		{name: "Retry-After date is capped", reply: retryAfter(unavailable, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), failures: 1, retries: 1, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := vmtest.NewServer(t)
+			srv.HandleFunc(vmtest.Any(), failing(tt.failures, tt.reply, &calls))
+			c := srv.Client(t, vm.Config{
+				MaxRetries:     tt.retries,
+				RetryBaseDelay: time.Millisecond,
+				RetryMaxDelay:  20 * time.Millisecond,
+			})
+
+			start := time.Now()
+			samples, _, err := c.QueryVector(context.Background(), vm.QueryOptions{Expr: testExpr})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(samples) != 1 {
+				t.Errorf("got %d samples, want 1", len(samples))
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server saw %d queries, want %d", got, tt.wantCalls)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %s, delays were not capped", elapsed)
+			}
+		})
+	}
+}
+
+func TestClientRetryStopsOnCancel(t *testing.T) {
+	srv := vmtest.NewServer(t)
+	srv.Handle(vmtest.Any(), vmtest.Fail(http.StatusServiceUnavailable, "unavailable", "try later"))
+	c := srv.Client(t, vm.Config{MaxRetries: 10, RetryBaseDelay: time.Hour, RetryMaxDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.QueryVector(ctx, vm.QueryOptions{Expr: testExpr}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("server saw %d queries, want 1", n)
+	}
+}
+
+func TestClientPartialResponses(t *testing.T) {
+	partial := vmtest.Vector(vmtest.Sample(1, "job", "api")).Partial()
+	partialMatrix := vmtest.Matrix(vmtest.Series([]vm.Point{{Value: 1}}, "job", "api")).Partial()
+	rangeOpts := vm.QueryOptions{Expr: testExpr, Start: "0", End: "60", Step: "15"}
+
+	// Every query method shares one decode path
+	methods := []struct {
+		name string
+		run  func(*vm.Client) ([]string, error)
+	}{
+		{"QueryVector", func(c *vm.Client) ([]string, error) {
+			_, resp, err := c.QueryVector(context.Background(), vm.QueryOptions{Expr: testExpr})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Warnings, nil
+		}},
+		{"QueryMatrix", func(c *vm.Client) ([]string, error) {
+			_, resp, err := c.QueryMatrix(context.Background(), rangeOpts)
+			if err != nil {
+				return nil, err
+			}
+			return resp.Warnings, nil
+		}},
+		{"Query", func(c *vm.Client) ([]string, error) {
+			_, err := c.Query(context.Background(), vm.QueryOptions{Expr: testExpr})
+			return nil, err
+		}},
+		{"QueryRange", func(c *vm.Client) ([]string, error) {
+			_, err := c.QueryRange(context.Background(), rangeOpts)
+			return nil, err
+		}},
+	}
+
+	for _, m := range methods {
+		for _, policy := range []vm.PartialPolicy{vm.PartialReject, vm.PartialWarn, vm.PartialAccept} {
+			t.Run(m.name+"/"+string(policy), func(t *testing.T) {
+				srv := vmtest.NewServer(t)
+				srv.HandleFunc(vmtest.Any(), func(r vmtest.Request) vmtest.Reply {
+					if r.Kind == "query_range" {
+						return partialMatrix
+					}
+					return partial
+				})
+
+				warnings, err := m.run(srv.Client(t, vm.Config{Partial: policy}))
+				switch policy {
+				case vm.PartialReject:
+					if !errors.Is(err, vm.ErrPartialResponse) {
+						t.Fatalf("err = %v, want %v", err, vm.ErrPartialResponse)
+					}
+					return
+				default:
+					if err != nil {
+						t.Fatalf("err = %v", err)
+					}
+				}
+
+				warned := slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, "partial response") })
+				if m.name == "QueryVector" || m.name == "QueryMatrix" {
+					if want := policy == vm.PartialWarn; warned != want {
+						t.Errorf("partial warning = %v, want %v (warnings %q)", warned, want, warnings)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestClientRejectsErrorStatus(t *testing.T) {
+	srv := vmtest.NewServer(t)
+	srv.Handle(vmtest.Any(), vmtest.Fail(http.StatusOK, "execution", "query timed out"))
+
+	_, err := srv.Client(t, vm.Config{}).Query(context.Background(), vm.QueryOptions{Expr: testExpr})
+	if err == nil || !strings.Contains(err.Error(), "query timed out") {
+		t.Fatalf("err = %v, want the API error", err)
+	}
+}
@@ -0,0 +1,12 @@
+package vm
+
+import "context"
+
+// Datasource is a Prometheus-compatible query API. *Client implements it
+// for every Backend; services depend on this interface only.
+type Datasource interface {
+	QueryVector(ctx context.Context, opts QueryOptions) ([]Sample, *Response, error)
+	QueryMatrix(ctx context.Context, opts QueryOptions) ([]Series, *Response, error)
+}
+
+var _ Datasource = (*Client)(nil)
@@ -1,15 +1,42 @@
 package vm
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 type QueryOptions struct {
 	Expr  string
 	Start string
 	End   string
 	Step  string
+
+	// Time is the evaluation timestamp of an instant query (default: now).
+	Time string
 }
 
+// Query runs an instant query against /api/v1/query. The body is returned
+// only once it decodes and passes the client's partial-response policy.
 func (c *Client) Query(ctx context.Context, opts QueryOptions) ([]byte, error) {
+	resp, err := c.instant(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Raw, nil
+}
+
+// QueryRange runs a range query against /api/v1/query_range.
+// Start, End and Step are required (RFC3339 or unix seconds; Step as duration or seconds).
+// Like Query, it applies the client's partial-response policy.
+func (c *Client) QueryRange(ctx context.Context, opts QueryOptions) ([]byte, error) {
+	resp, err := c.rangeQuery(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Raw, nil
+}
+
+func (c *Client) instant(ctx context.Context, opts QueryOptions) (*Response, error) {
 	params := map[string]string{
 		"query": opts.Expr,
 	}
@@ -23,10 +50,72 @@ func (c *Client) Query(ctx context.Context, opts QueryOptions) ([]byte, error) {
 	if opts.Step != "" {
 		params["step"] = opts.Step
 	}
+	if opts.Time != "" {
+		params["time"] = opts.Time
+	}
+
+	return c.get(ctx, "/api/v1/query", params)
+}
+
+func (c *Client) rangeQuery(ctx context.Context, opts QueryOptions) (*Response, error) {
+	if opts.Start == "" || opts.End == "" || opts.Step == "" {
+		return nil, fmt.Errorf("range query requires start, end and step")
+	}
+
+	return c.get(ctx, "/api/v1/query_range", map[string]string{
+		"query": opts.Expr,
+		"start": opts.Start,
+		"end":   opts.End,
+		"step":  opts.Step,
+	})
+}
+
+// get is the shared request and decode path of every query method.
+func (c *Client) get(ctx context.Context, path string, params map[string]string) (*Response, error) {
+	raw, err := c.doGET(ctx, c.flavor.apiPrefix()+path, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(raw)
+}
+
+// QueryVector runs an instant query and decodes the vector result.
+func (c *Client) QueryVector(ctx context.Context, opts QueryOptions) ([]Sample, *Response, error) {
+	resp, err := c.instant(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	samples, err := resp.Vector()
+	return samples, resp, err
+}
+
+// QueryMatrix runs a range query and decodes the matrix result.
+func (c *Client) QueryMatrix(ctx context.Context, opts QueryOptions) ([]Series, *Response, error) {
+	resp, err := c.rangeQuery(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	series, err := resp.Matrix()
+	return series, resp, err
+}
 
-	return c.doGET(
-		ctx,
-		"/select/0/prometheus/api/v1/query",
-		params,
-	)
+// decode parses a response and applies the client's partial-response policy.
+// Under PartialWarn the response is kept and a warning is appended to it.
+func (c *Client) decode(raw []byte) (*Response, error) {
+	resp, err := Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsPartial {
+		return resp, nil
+	}
+
+	switch c.partial {
+	case PartialAccept:
+	case PartialWarn:
+		resp.Warnings = append(resp.Warnings, "partial response: some storage nodes were unavailable")
+	default:
+		return nil, ErrPartialResponse
+	}
+	return resp, nil
 }
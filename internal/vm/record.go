@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recorder wraps a Datasource and persists every query and its raw response
+// (or error) under Dir, so the run can later be served by a Replayer.
+type Recorder struct {
+	Inner Datasource
+	Dir   string
+}
+
+// Replayer serves queries from a directory written by a Recorder.
+// It never touches the network; unknown queries are an error.
+type Replayer struct {
+	Dir string
+}
+
+var (
+	_ Datasource = (*Recorder)(nil)
+	_ Datasource = (*Replayer)(nil)
+)
+
+// recording is the on-disk format: one JSON file per distinct query.
+type recording struct {
+	Kind     string          `json:"kind"` // query | query_range
+	Query    QueryOptions    `json:"query"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Error    string          `json:"error,omitempty"`
+
+	// ErrorKind names a sentinel the error wraps (see errorKinds), so a
+	// replayed error matches errors.Is like the live one did
+	ErrorKind string `json:"error_kind,omitempty"`
+}
+
+// errorKinds are the sentinel errors a recording preserves.
+var errorKinds = []struct {
+	name string
+	err  error
+}{
+	{"partial_response", ErrPartialResponse},
+	{"deadline_exceeded", context.DeadlineExceeded},
+	{"canceled", context.Canceled},
+}
+
+const (
+	kindQuery      = "query"
+	kindQueryRange = "query_range"
+)
+
+func NewRecorder(inner Datasource, dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("record dir: %w", err)
+	}
+	return &Recorder{Inner: inner, Dir: dir}, nil
+}
+
+func NewReplayer(dir string) (*Replayer, error) {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("replay dir: %w", err)
+	}
+	if !st.IsDir() {
+		return nil, fmt.Errorf("replay dir: %s is not a directory", dir)
+	}
+	return &Replayer{Dir: dir}, nil
+}
+
+// recordingPath names a recording by a hash of the query kind and options.
+func recordingPath(dir, kind string, opts QueryOptions) string {
+	key, _ := json.Marshal(struct {
+		Kind  string       `json:"kind"`
+		Query QueryOptions `json:"query"`
+	}{kind, opts})
+	sum := sha256.Sum256(key)
+	return filepath.Join(dir, kind+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+func (r *Recorder) QueryVector(ctx context.Context, opts QueryOptions) ([]Sample, *Response, error) {
+	samples, resp, err := r.Inner.QueryVector(ctx, opts)
+	if werr := r.save(kindQuery, opts, resp, err); werr != nil {
+		return nil, nil, werr
+	}
+	return samples, resp, err
+}
+
+func (r *Recorder) QueryMatrix(ctx context.Context, opts QueryOptions) ([]Series, *Response, error) {
+	series, resp, err := r.Inner.QueryMatrix(ctx, opts)
+	if werr := r.save(kindQueryRange, opts, resp, err); werr != nil {
+		return nil, nil, werr
+	}
+	return series, resp, err
+}
+
+func (r *Recorder) save(kind string, opts QueryOptions, resp *Response, qerr error) error {
+	rec := recording{Kind: kind, Query: opts}
+	if qerr != nil {
+		rec.Error = qerr.Error()
+		for _, k := range errorKinds {
+			if errors.Is(qerr, k.err) {
+				rec.ErrorKind = k.name
+				break
+			}
+		}
+	}
+	if resp != nil {
+		rec.Response = resp.Raw
+		rec.Warnings = resp.Warnings
+	}
+
+	raw, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("record %s: %w", kind, err)
+	}
+	if err := os.WriteFile(recordingPath(r.Dir, kind, opts), raw, 0o644); err != nil {
+		return fmt.Errorf("record %s: %w", kind, err)
+	}
+	return nil
+}
+
+func (r *Replayer) load(kind string, opts QueryOptions) (*Response, error) {
+	path := recordingPath(r.Dir, kind, opts)
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("replay: no recording for %s %q", kind, opts.Expr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	var rec recording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if rec.Error != "" {
+		return nil, replayedError(rec.Error, rec.ErrorKind)
+	}
+
+	resp, err := Decode(rec.Response)
+	if err != nil {
+		return nil, err
+	}
+	resp.Warnings = rec.Warnings
+	return resp, nil
+}
+
+// replayedError rebuilds a recorded error with the same message, wrapping
+// its sentinel when the recording names one.
+func replayedError(msg, kind string) error {
+	for _, k := range errorKinds {
+		if k.name != kind {
+			continue
+		}
+		if msg == k.err.Error() {
+			return k.err
+		}
+		if prefix, ok := strings.CutSuffix(msg, ": "+k.err.Error()); ok {
+			return fmt.Errorf("%s: %w", prefix, k.err)
+		}
+		return fmt.Errorf("%s: %w", msg, k.err)
+	}
+	return errors.New(msg)
+}
+
+func (r *Replayer) QueryVector(_ context.Context, opts QueryOptions) ([]Sample, *Response, error) {
+	resp, err := r.load(kindQuery, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	samples, err := resp.Vector()
+	return samples, resp, err
+}
+
+func (r *Replayer) QueryMatrix(_ context.Context, opts QueryOptions) ([]Series, *Response, error) {
+	resp, err := r.load(kindQueryRange, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	series, err := resp.Matrix()
+	return series, resp, err
+}
@@ -0,0 +1,93 @@
+package vm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm/vmtest"
+)
+
+// wrapped adds context to a datasource's errors the way callers do.
+type wrapped struct{ vm.Datasource }
+
+func (w wrapped) QueryVector(ctx context.Context, opts vm.QueryOptions) ([]vm.Sample, *vm.Response, error) {
+	samples, resp, err := w.Datasource.QueryVector(ctx, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("memory requests: %w", err)
+	}
+	return samples, resp, nil
+}
+
+func TestReplayMatchesRecording(t *testing.T) {
+	srv := vmtest.NewServer(t)
+	srv.HandleExpr("ok", vmtest.Vector(vmtest.Sample(2, "job", "api")).WithWarnings("slow"))
+	srv.HandleExpr("partial", vmtest.Vector(vmtest.Sample(1, "job", "api")).Partial())
+	srv.HandleExpr("broken", vmtest.Fail(http.StatusBadRequest, "bad_data", "parse error"))
+
+	tests := []struct {
+		name    string
+		inner   func(vm.Datasource) vm.Datasource
+		expr    string
+		partial bool
+	}{
+		{name: "success", expr: "ok"},
+		{name: "partial response", expr: "partial", partial: true},
+		{name: "wrapped partial response", inner: func(d vm.Datasource) vm.Datasource { return wrapped{d} }, expr: "partial", partial: true},
+		{name: "api error", expr: "broken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var inner vm.Datasource = srv.Client(t, vm.Config{})
+			if tt.inner != nil {
+				inner = tt.inner(inner)
+			}
+			rec, err := vm.NewRecorder(inner, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := vm.QueryOptions{Expr: tt.expr}
+			liveSamples, liveResp, liveErr := rec.QueryVector(context.Background(), opts)
+
+			rep, err := vm.NewReplayer(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			samples, resp, err := rep.QueryVector(context.Background(), opts)
+
+			if (err == nil) != (liveErr == nil) {
+				t.Fatalf("replay err = %v, live err = %v", err, liveErr)
+			}
+			if err != nil {
+				if err.Error() != liveErr.Error() {
+					t.Errorf("replay err = %q, want %q", err, liveErr)
+				}
+				if got := errors.Is(err, vm.ErrPartialResponse); got != tt.partial {
+					t.Errorf("errors.Is(replay err, ErrPartialResponse) = %v, want %v", got, tt.partial)
+				}
+				return
+			}
+			if len(samples) != len(liveSamples) || samples[0].Value != liveSamples[0].Value {
+				t.Errorf("replay samples = %v, want %v", samples, liveSamples)
+			}
+			if fmt.Sprint(resp.Warnings) != fmt.Sprint(liveResp.Warnings) {
+				t.Errorf("replay warnings = %q, want %q", resp.Warnings, liveResp.Warnings)
+			}
+		})
+	}
+}
+
+func TestReplayUnknownQuery(t *testing.T) {
+	rep, err := vm.NewReplayer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rep.QueryVector(context.Background(), vm.QueryOptions{Expr: "up"}); err == nil {
+		t.Fatal("expected an error for a query that was never recorded")
+	}
+}
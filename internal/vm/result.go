@@ -0,0 +1,171 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type ResultType string
+
+const (
+	ResultVector ResultType = "vector"
+	ResultMatrix ResultType = "matrix"
+	ResultScalar ResultType = "scalar"
+	ResultString ResultType = "string"
+)
+
+// Response is the Prometheus HTTP API envelope, including the
+// VictoriaMetrics extensions (stats, isPartial).
+type Response struct {
+	Status    string   `json:"status"`
+	Data      Data     `json:"data"`
+	ErrorType string   `json:"errorType,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+
+	// VictoriaMetrics only
+	IsPartial bool   `json:"isPartial,omitempty"`
+	Stats     *Stats `json:"stats,omitempty"`
+
+	// Raw is the undecoded body, kept for record/replay.
+	Raw json.RawMessage `json:"-"`
+}
+
+type Data struct {
+	ResultType ResultType      `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// Stats is reported by vmselect. seriesFetched is sent as a string.
+type Stats struct {
+	SeriesFetched     json.Number `json:"seriesFetched"`
+	ExecutionTimeMsec int64       `json:"executionTimeMsec"`
+}
+
+type Metric map[string]string
+
+// Point is a single [unixSeconds, "value"] pair.
+type Point struct {
+	Timestamp float64
+	Value     float64
+}
+
+func (p Point) Time() time.Time {
+	sec, frac := math.Modf(p.Timestamp)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+func (p *Point) UnmarshalJSON(b []byte) error {
+	var raw [2]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("point: %w", err)
+	}
+	if err := json.Unmarshal(raw[0], &p.Timestamp); err != nil {
+		return fmt.Errorf("point timestamp: %w", err)
+	}
+	var s string
+	if err := json.Unmarshal(raw[1], &s); err != nil {
+		return fmt.Errorf("point value: %w", err)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("point value %q: %w", s, err)
+	}
+	p.Value = v
+	return nil
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]any{p.Timestamp, strconv.FormatFloat(p.Value, 'f', -1, 64)})
+}
+
+// Sample is one series of an instant vector.
+type Sample struct {
+	Metric Metric `json:"metric"`
+	Value  Point  `json:"value"`
+}
+
+// Series is one series of a range matrix.
+type Series struct {
+	Metric Metric  `json:"metric"`
+	Values []Point `json:"values"`
+}
+
+// Decode parses a raw API response and fails on status!=success.
+func Decode(raw []byte) (*Response, error) {
+	var resp Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	resp.Raw = raw
+	if resp.Status != "success" {
+		if resp.Error != "" {
+			return nil, fmt.Errorf("vm status=%s (%s): %s", resp.Status, resp.ErrorType, resp.Error)
+		}
+		return nil, fmt.Errorf("vm status=%s", resp.Status)
+	}
+	return &resp, nil
+}
+
+func (r *Response) expect(t ResultType) error {
+	if r.Data.ResultType != t {
+		return fmt.Errorf("expected %s result, got %q", t, r.Data.ResultType)
+	}
+	return nil
+}
+
+func (r *Response) Vector() ([]Sample, error) {
+	if err := r.expect(ResultVector); err != nil {
+		return nil, err
+	}
+	var out []Sample
+	if err := json.Unmarshal(r.Data.Result, &out); err != nil {
+		return nil, fmt.Errorf("decode vector: %w", err)
+	}
+	return out, nil
+}
+
+func (r *Response) Matrix() ([]Series, error) {
+	if err := r.expect(ResultMatrix); err != nil {
+		return nil, err
+	}
+	var out []Series
+	if err := json.Unmarshal(r.Data.Result, &out); err != nil {
+		return nil, fmt.Errorf("decode matrix: %w", err)
+	}
+	return out, nil
+}
+
+func (r *Response) Scalar() (Point, error) {
+	var p Point
+	if err := r.expect(ResultScalar); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(r.Data.Result, &p); err != nil {
+		return p, fmt.Errorf("decode scalar: %w", err)
+	}
+	return p, nil
+}
+
+// StringValue returns the timestamp and value of a string result.
+func (r *Response) StringValue() (float64, string, error) {
+	if err := r.expect(ResultString); err != nil {
+		return 0, "", err
+	}
+	var raw [2]json.RawMessage
+	if err := json.Unmarshal(r.Data.Result, &raw); err != nil {
+		return 0, "", fmt.Errorf("decode string: %w", err)
+	}
+	var ts float64
+	var s string
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return 0, "", fmt.Errorf("decode string timestamp: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &s); err != nil {
+		return 0, "", fmt.Errorf("decode string value: %w", err)
+	}
+	return ts, s, nil
+}
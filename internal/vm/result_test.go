@@ -0,0 +1,115 @@
+package vm_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+)
+
+func decode(t *testing.T, body string) *vm.Response {
+	t.Helper()
+
+	resp, err := vm.Decode([]byte(body))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	return resp
+}
+
+func TestDecodeVector(t *testing.T) {
+	resp := decode(t, `{"status":"success","isPartial":false,"stats":{"seriesFetched":"2","executionTimeMsec":3},"data":{"resultType":"vector","result":[
+		{"metric":{"container":"api"},"value":[1700000000.5,"0.25"]},
+		{"metric":{"container":"web"},"value":[1700000000.5,"NaN"]}
+	]}}`)
+
+	samples, err := resp.Vector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Metric["container"] != "api" || samples[0].Value.Value != 0.25 {
+		t.Fatalf("samples = %+v", samples)
+	}
+	if !math.IsNaN(samples[1].Value.Value) {
+		t.Errorf("value = %v, want NaN", samples[1].Value.Value)
+	}
+	if got := samples[0].Value.Time(); !got.Equal(time.Unix(1700000000, 5e8)) {
+		t.Errorf("time = %v", got)
+	}
+	if resp.Stats == nil || resp.Stats.SeriesFetched != "2" {
+		t.Errorf("stats = %+v", resp.Stats)
+	}
+
+	if _, err := resp.Matrix(); err == nil {
+		t.Error("Matrix on a vector result succeeded")
+	}
+}
+
+func TestDecodeMatrix(t *testing.T) {
+	resp := decode(t, `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"container":"api"},"values":[[60,"1"],[120,"+Inf"],[180,"3e2"]]}
+	]}}`)
+
+	series, err := resp.Matrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || len(series[0].Values) != 3 {
+		t.Fatalf("series = %+v", series)
+	}
+	if v := series[0].Values; v[0].Timestamp != 60 || !math.IsInf(v[1].Value, 1) || v[2].Value != 300 {
+		t.Errorf("values = %+v", v)
+	}
+	if _, err := resp.Vector(); err == nil {
+		t.Error("Vector on a matrix result succeeded")
+	}
+}
+
+func TestDecodeScalarAndString(t *testing.T) {
+	p, err := decode(t, `{"status":"success","data":{"resultType":"scalar","result":[10,"42"]}}`).Scalar()
+	if err != nil || p.Timestamp != 10 || p.Value != 42 {
+		t.Errorf("Scalar = %+v, %v", p, err)
+	}
+
+	ts, s, err := decode(t, `{"status":"success","data":{"resultType":"string","result":[10,"hello"]}}`).StringValue()
+	if err != nil || ts != 10 || s != "hello" {
+		t.Errorf("StringValue = %v %q, %v", ts, s, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"error status":   `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+		"missing status": `{"data":{"resultType":"vector","result":[]}}`,
+		"not json":       `<html>bad gateway</html>`,
+	}
+	for name, body := range tests {
+		if _, err := vm.Decode([]byte(body)); err == nil {
+			t.Errorf("%s: Decode succeeded", name)
+		}
+	}
+
+	bad := decode(t, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"abc"]}]}}`)
+	if _, err := bad.Vector(); err == nil {
+		t.Error("Vector with a non-numeric value succeeded")
+	}
+}
+
+func TestPointRoundTrip(t *testing.T) {
+	for _, v := range []float64{0, 0.1, -3.5, 1e20, math.Inf(1), math.Inf(-1)} {
+		in := vm.Point{Timestamp: 1700000000.25, Value: v}
+		raw, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out vm.Point
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		if out != in {
+			t.Errorf("%s round-tripped to %+v, want %+v", raw, out, in)
+		}
+	}
+}
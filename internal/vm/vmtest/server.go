@@ -0,0 +1,282 @@
+// Package vmtest provides an in-process fake of the Prometheus HTTP query
+// API (as served by vmselect, Prometheus, Thanos or Mimir) for tests.
+package vmtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+)
+
+// Request is a query received by the fake server.
+type Request struct {
+	Kind   string // "query" | "query_range"
+	Path   string
+	Expr   string
+	Params url.Values
+	Header http.Header
+}
+
+// Matcher selects the PromQL expressions a canned reply applies to.
+type Matcher func(expr string) bool
+
+// Exact matches an expression after collapsing whitespace, so the
+// multi-line templates in internal/promql can be registered verbatim.
+func Exact(expr string) Matcher {
+	want := normalize(expr)
+	return func(got string) bool { return normalize(got) == want }
+}
+
+func Contains(sub string) Matcher {
+	return func(expr string) bool { return strings.Contains(expr, sub) }
+}
+
+func Regexp(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return re.MatchString
+}
+
+func Any() Matcher {
+	return func(string) bool { return true }
+}
+
+func normalize(expr string) string {
+	return strings.Join(strings.Fields(expr), " ")
+}
+
+type route struct {
+	match Matcher
+	reply func(Request) Reply
+}
+
+// Server is a fake query API. Routes are matched in registration order;
+// the first match wins. Unmatched queries get an empty successful result.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	routes   []route
+	requests []Request
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Handle registers a canned reply for matching expressions.
+func (s *Server) Handle(m Matcher, r Reply) {
+	s.HandleFunc(m, func(Request) Reply { return r })
+}
+
+// HandleExpr is Handle(Exact(expr), r).
+func (s *Server) HandleExpr(expr string, r Reply) {
+	s.Handle(Exact(expr), r)
+}
+
+// HandleFunc registers a dynamic reply (e.g. fail first, then succeed).
+func (s *Server) HandleFunc(m Matcher, fn func(Request) Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, route{match: m, reply: fn})
+}
+
+// Requests returns a copy of every query received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Exprs returns the expressions received so far, in arrival order.
+func (s *Server) Exprs() []string {
+	reqs := s.Requests()
+	out := make([]string, len(reqs))
+	for i, r := range reqs {
+		out[i] = r.Expr
+	}
+	return out
+}
+
+// Client returns a vm.Client pointed at the server. cfg.BaseURL is overridden.
+func (s *Server) Client(t testing.TB, cfg vm.Config) *vm.Client {
+	t.Helper()
+
+	cfg.BaseURL = s.URL
+	c, err := vm.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("vmtest: new client: %v", err)
+	}
+	return c
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var kind string
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/api/v1/query_range"):
+		kind = "query_range"
+	case strings.HasSuffix(r.URL.Path, "/api/v1/query"):
+		kind = "query"
+	case r.URL.Path == "/-/healthy", r.URL.Path == "/health", r.URL.Path == "/ready":
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		Fail(http.StatusBadRequest, "bad_data", err.Error()).write(w)
+		return
+	}
+
+	req := Request{
+		Kind:   kind,
+		Path:   r.URL.Path,
+		Expr:   r.Form.Get("query"),
+		Params: r.Form,
+		Header: r.Header.Clone(),
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var reply func(Request) Reply
+	for _, rt := range s.routes {
+		if rt.match(req.Expr) {
+			reply = rt.reply
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if reply == nil {
+		if kind == "query_range" {
+			Matrix().write(w)
+		} else {
+			Vector().write(w)
+		}
+		return
+	}
+	reply(req).write(w)
+}
+
+// ---------------------------------------------------------------------
+// Replies
+// ---------------------------------------------------------------------
+
+// Reply is a canned HTTP response.
+type Reply struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+func (r Reply) write(w http.ResponseWriter) {
+	for k, v := range r.Header {
+		w.Header()[k] = v
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(r.Body)
+}
+
+type envelope struct {
+	Status    string   `json:"status"`
+	Data      any      `json:"data,omitempty"`
+	ErrorType string   `json:"errorType,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+	IsPartial bool     `json:"isPartial,omitempty"`
+}
+
+type data struct {
+	ResultType vm.ResultType `json:"resultType"`
+	Result     any           `json:"result"`
+}
+
+func success(t vm.ResultType, result any) Reply {
+	body, _ := json.Marshal(envelope{Status: "success", Data: data{ResultType: t, Result: result}})
+	return Reply{Body: body}
+}
+
+// Sample builds an instant sample from a value and label name/value pairs.
+func Sample(value float64, labels ...string) vm.Sample {
+	return vm.Sample{Metric: metric(labels), Value: vm.Point{Value: value}}
+}
+
+// Series builds a range series from points and label name/value pairs.
+func Series(points []vm.Point, labels ...string) vm.Series {
+	return vm.Series{Metric: metric(labels), Values: points}
+}
+
+func metric(labels []string) vm.Metric {
+	m := vm.Metric{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		m[labels[i]] = labels[i+1]
+	}
+	return m
+}
+
+func Vector(samples ...vm.Sample) Reply {
+	if samples == nil {
+		samples = []vm.Sample{}
+	}
+	return success(vm.ResultVector, samples)
+}
+
+func Matrix(series ...vm.Series) Reply {
+	if series == nil {
+		series = []vm.Series{}
+	}
+	return success(vm.ResultMatrix, series)
+}
+
+func Scalar(value float64) Reply {
+	return success(vm.ResultScalar, vm.Point{Value: value})
+}
+
+// Fail replies with a Prometheus-style error body.
+func Fail(status int, errorType, msg string) Reply {
+	body, _ := json.Marshal(envelope{Status: "error", ErrorType: errorType, Error: msg})
+	return Reply{Status: status, Body: body}
+}
+
+// Partial marks a successful reply as a VictoriaMetrics partial response.
+func (r Reply) Partial() Reply {
+	var env map[string]any
+	if err := json.Unmarshal(r.Body, &env); err != nil {
+		return r
+	}
+	env["isPartial"] = true
+	r.Body, _ = json.Marshal(env)
+	return r
+}
+
+// WithWarnings adds Prometheus API warnings to a reply.
+func (r Reply) WithWarnings(warnings ...string) Reply {
+	var env map[string]any
+	if err := json.Unmarshal(r.Body, &env); err != nil {
+		return r
+	}
+	env["warnings"] = warnings
+	r.Body, _ = json.Marshal(env)
+	return r
+}
@@ -0,0 +1,80 @@
+package vmtest_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm"
+	"github.com/BenjaminVolodarsky/cloud-monitoring-sentinel/internal/vm/vmtest"
+)
+
+func TestServerRoutes(t *testing.T) {
+	srv := vmtest.NewServer(t)
+	srv.HandleExpr("sum by (container) (\n  up{job=\"api\"}\n)", vmtest.Vector(vmtest.Sample(1, "container", "exact")))
+	srv.Handle(vmtest.Contains("up{"), vmtest.Vector(vmtest.Sample(2, "container", "contains")))
+	srv.Handle(vmtest.Regexp(`^rate\(`), vmtest.Fail(http.StatusBadRequest, "bad_data", "no rates"))
+	srv.Handle(vmtest.Contains("up{"), vmtest.Vector(vmtest.Sample(3, "container", "shadowed")))
+	c := srv.Client(t, vm.Config{})
+
+	tests := []struct {
+		expr    string
+		want    string // container label of the reply
+		wantErr bool
+	}{
+		// Whitespace is collapsed before an exact match
+		{expr: `sum by (container) ( up{job="api"} )`, want: "exact"},
+		// The first matching route wins
+		{expr: `up{job="web"}`, want: "contains"},
+		{expr: `rate(x[5m])`, wantErr: true},
+		// Unmatched queries succeed empty
+		{expr: `other`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			samples, _, err := c.QueryVector(context.Background(), vm.QueryOptions{Expr: tt.expr})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			var got string
+			if len(samples) > 0 {
+				got = samples[0].Metric["container"]
+			}
+			if got != tt.want {
+				t.Errorf("reply from %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := srv.Exprs(), []string{`sum by (container) ( up{job="api"} )`, `up{job="web"}`, `rate(x[5m])`, `other`}; !slices.Equal(got, want) {
+		t.Errorf("Exprs = %q, want %q", got, want)
+	}
+}
+
+func TestServerRecordsRequests(t *testing.T) {
+	srv := vmtest.NewServer(t)
+	srv.Handle(vmtest.Any(), vmtest.Matrix(vmtest.Series([]vm.Point{{Timestamp: 60, Value: 1}}, "job", "api")).WithWarnings("slow"))
+	c := srv.Client(t, vm.Config{BearerToken: "secret"})
+
+	series, resp, err := c.QueryMatrix(context.Background(), vm.QueryOptions{Expr: "up", Start: "0", End: "120", Step: "60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Values[0].Value != 1 || !slices.Contains(resp.Warnings, "slow") {
+		t.Errorf("series = %+v, warnings = %q", series, resp.Warnings)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(reqs))
+	}
+	r := reqs[0]
+	if r.Kind != "query_range" || r.Expr != "up" || r.Params.Get("step") != "60" {
+		t.Errorf("request = %+v", r)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+}